@@ -2,19 +2,26 @@ package nlcall
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"github.com/HFrost0/nlcall/function"
+	"log"
 	"os"
 	"reflect"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Agent struct {
-	resolver Resolver
-	definer  Definer
-	funcMap  map[string]*function.Function
-	funcKeys []string
+	resolver  Resolver
+	definer   Definer
+	funcMap   map[string]*function.Function
+	funcKeys  []string
+	toolsets  map[string][]string // the names of the functions in each toolset
+	providers map[reflect.Type]Provider
+	// guards providers, which are read by concurrent calls
+	providersMu sync.RWMutex
 }
 
 func NewAgent(resolver Resolver, definer Definer) *Agent {
@@ -22,15 +29,20 @@ func NewAgent(resolver Resolver, definer Definer) *Agent {
 		resolver: resolver,
 		definer:  definer,
 		funcMap:  make(map[string]*function.Function),
+		toolsets: make(map[string][]string),
+		providers: map[reflect.Type]Provider{
+			contextType: func(ctx context.Context) (any, error) { return ctx, nil },
+		},
 	}
 }
 
-// AssignFunc assigns the user input to the corresponding function.Function
-func (a *Agent) AssignFunc(ctx context.Context, userInput string) (f *function.Function, rawParams []string, err error) {
+// AssignFunc assigns the user input to the corresponding function.Function,
+// which can be restricted to some toolsets or functions by the options
+func (a *Agent) AssignFunc(ctx context.Context, userInput string, opts ...CallOption) (f *function.Function, rawParams []string, err error) {
 	if userInput == "" {
 		return nil, nil, EmptyUserInputErr
 	}
-	call, err := a.resolver.Resolve(ctx, userInput)
+	call, err := a.resolve(ctx, userInput, opts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -41,11 +53,14 @@ func (a *Agent) AssignFunc(ctx context.Context, userInput string) (f *function.F
 	return f, rawParams, nil
 }
 
-func (a *Agent) AssignCallable(ctx context.Context, userInput string) (callable function.Callable, err error) {
+// AssignCallable assigns the user input to the callable of the corresponding function,
+// which can be restricted to some toolsets or functions by the options.
+// The callable panics as the function does, call it by SafeCall to get the panic as PanicErr instead
+func (a *Agent) AssignCallable(ctx context.Context, userInput string, opts ...CallOption) (callable function.Callable, err error) {
 	if userInput == "" {
 		return nil, EmptyUserInputErr
 	}
-	call, err := a.resolver.Resolve(ctx, userInput)
+	call, err := a.resolve(ctx, userInput, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -53,11 +68,75 @@ func (a *Agent) AssignCallable(ctx context.Context, userInput string) (callable
 	if err != nil {
 		return nil, err
 	}
+	// nested calls are made before the callable is returned
+	if call, err = a.evalNested(ctx, call); err != nil {
+		return nil, err
+	}
 	callable, err = f.GetCallable(call.Params)
 	if err != nil {
 		return nil, err
 	}
-	return callable, nil
+	// the providers are called now, so their errors are returned instead of raised by the callable
+	injected, err := a.Inject(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return injectCallable(injected, callable), nil
+}
+
+// Call resolves the user input and calls the function with the injected parameters, see Invoke.
+// The functions can be restricted to some toolsets or functions by the options
+func (a *Agent) Call(ctx context.Context, userInput string, opts ...CallOption) (*function.Result, error) {
+	if userInput == "" {
+		return nil, EmptyUserInputErr
+	}
+	call, err := a.resolve(ctx, userInput, opts)
+	if err != nil {
+		return nil, err
+	}
+	return a.Invoke(ctx, call)
+}
+
+// Invoke calls the resolved call with the injected parameters.
+// It returns TimeoutErr once the deadline of ctx or the timeout of the function is exceeded
+// and CanceledErr once ctx is cancelled, in which case the injected context.Context is cancelled as well.
+// A panic of the function is recovered and returned as PanicErr.
+// Nested calls in the parameters are invoked first and their results are passed as arguments
+func (a *Agent) Invoke(ctx context.Context, call *function.Call) (*function.Result, error) {
+	f, err := a.GetFunc(call.Name)
+	if err != nil {
+		return nil, err
+	}
+	if call, err = a.evalNested(ctx, call); err != nil {
+		return nil, err
+	}
+	// the injected context is cancelled once Invoke returns, the timeout of the function is applied by InvokeContext
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ignoreParams, err := a.Inject(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	return f.InvokeContext(ctx, call.Params, ignoreParams...)
+}
+
+// evalNested invokes the nested calls in the parameters bottom-up and returns the call with their results
+func (a *Agent) evalNested(ctx context.Context, call *function.Call) (*function.Call, error) {
+	if !call.Params.HasNested() {
+		return call, nil
+	}
+	params, err := call.Params.Eval(func(nested *function.Call) (string, error) {
+		res, err := a.Invoke(ctx, nested)
+		if err != nil {
+			return "", err
+		}
+		b, err := res.JSON()
+		return string(b), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &function.Call{Name: call.Name, Params: params}, nil
 }
 
 // RegisterFunc registers a function.Function to be called
@@ -78,11 +157,31 @@ func (a *Agent) RegisterFunc(f *function.Function) error {
 type RegisterOption func(*RegisterOpts)
 
 type RegisterOpts struct {
-	LoadDefDir string // the dir to load function definition
-	SaveDefDir string // the dir to save function definition
-	Overwrite  bool   // whether to overwrite the existing definition
+	LoadDefDir string         // the dir to load function definition
+	SaveDefDir string         // the dir to save function definition
+	Overwrite  bool           // whether to overwrite the existing definition
+	StaleDef   StaleDefPolicy // what to do when the loaded definition was generated for another source
+	// called with the def file name of a function whose loaded definition is stale instead of logging, see StaleDefWarn
+	StaleDefHook func(fnName string, dir string)
+	Namespace    string // the prefix of method names registered by RegisterObject, type name by default
+	// explicit definition, the Definer is skipped when both Name and Description are given
+	Name        string   // the function name, which also names the def file
+	Description string   // the function description
+	ParamNames  []string // the parameter names by position, which are taken from source by default
+
+	Timeout  time.Duration // the default timeout of the function when invoked by Agent.Invoke
+	Toolsets []string      // the toolsets the function belongs to, see WithAllowedToolsets
 }
 
+// StaleDefPolicy decides what to do with a stale definition loaded from disk
+type StaleDefPolicy int
+
+const (
+	StaleDefWarn       StaleDefPolicy = iota // log a warning, or call the hook of WithStaleDefHook, and use the stale definition
+	StaleDefRegenerate                       // generate a new definition by the Definer
+	StaleDefError                            // fail the registration with StaleDefErr
+)
+
 func WithLoadDefDir(path string) RegisterOption {
 	return func(o *RegisterOpts) {
 		o.LoadDefDir = path
@@ -101,6 +200,54 @@ func WithOverwrite(overwrite bool) RegisterOption {
 	}
 }
 
+func WithStaleDef(policy StaleDefPolicy) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.StaleDef = policy
+	}
+}
+
+func WithStaleDefHook(hook func(fnName string, dir string)) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.StaleDefHook = hook
+	}
+}
+
+func WithName(name string) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.Name = name
+	}
+}
+
+func WithDescription(description string) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.Description = description
+	}
+}
+
+func WithParamNames(names ...string) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.ParamNames = names
+	}
+}
+
+func WithTimeout(timeout time.Duration) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.Timeout = timeout
+	}
+}
+
+func WithToolsets(toolsets ...string) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.Toolsets = append(o.Toolsets, toolsets...)
+	}
+}
+
+func WithNamespace(namespace string) RegisterOption {
+	return func(o *RegisterOpts) {
+		o.Namespace = namespace
+	}
+}
+
 func buildRegisterOpts(opts ...RegisterOption) RegisterOpts {
 	var registerOpts RegisterOpts
 	for _, opt := range opts {
@@ -111,34 +258,150 @@ func buildRegisterOpts(opts ...RegisterOption) RegisterOpts {
 
 // RegisterFn registers a golang function can be called
 // the golang fn definition can be generated by the Definer according to options
-// ignore idx in this case will not be considered
+// context.Context and the parameters of provided types are ignored and injected when called, see Provide
 func (a *Agent) RegisterFn(ctx context.Context, fn any, opts ...RegisterOption) (*function.Function, error) {
+	registerOpts := buildRegisterOpts(opts...)
+	fnName := getFnName(fn)
+	if registerOpts.Name != "" {
+		// the def file of a named function doesn't depend on where it's declared, e.g. closures like main.main.func1
+		fnName = getPkgPath(fnName) + "." + registerOpts.Name
+	}
+	return a.registerFn(ctx, fn, fnName, nil, registerOpts)
+}
+
+// RegisterObject registers every exported method of obj as a function named <Type>.<Method>,
+// methods with pointer receiver are included when obj is a pointer.
+// Methods promoted from embedded fields like sync.Mutex are not declared by the type and skipped.
+// The definitions are saved and loaded with file names like main.<Type>.<Method>,
+// and the methods belong to the toolset named by the namespace besides the given toolsets
+func (a *Agent) RegisterObject(ctx context.Context, obj any, opts ...RegisterOption) ([]*function.Function, error) {
+	v := reflect.ValueOf(obj)
+	t := v.Type()
+	base := t
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if base.Name() == "" {
+		return nil, fmt.Errorf("obj of unnamed type %s can not be registered", t)
+	}
+	registerOpts := buildRegisterOpts(opts...)
+	// explicit definitions are for single functions
+	registerOpts.Description, registerOpts.ParamNames = "", nil
+	namespace := registerOpts.Namespace
+	if namespace == "" {
+		namespace = base.Name()
+	}
+	registerOpts.Toolsets = append(append([]string(nil), registerOpts.Toolsets...), namespace)
+	// the method value has no source, locate the declarations by the receiver type before registering any
+	var methodIdx []int
+	var infos []*function.FuncInfo
+	for i := 0; i < t.NumMethod(); i++ {
+		name := t.Method(i).Name
+		if function.IsPromotedMethod(t, name) {
+			continue
+		}
+		info, err := function.GetMethodDetails(t, name)
+		if err != nil {
+			return nil, err
+		}
+		methodIdx = append(methodIdx, i)
+		infos = append(infos, info)
+	}
+	fs := make([]*function.Function, 0, len(methodIdx))
+	for j, i := range methodIdx {
+		info := infos[j]
+		registerOpts.Name = namespace + "." + t.Method(i).Name
+		f, err := a.registerFn(ctx, v.Method(i).Interface(), base.PkgPath()+"."+registerOpts.Name, info, registerOpts)
+		if err != nil {
+			return fs, err
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+// registerFn registers fn with its def file name fnName, info is nil when it can be found from fn
+func (a *Agent) registerFn(ctx context.Context, fn any, fnName string, info *function.FuncInfo, registerOpts RegisterOpts) (*function.Function, error) {
 	var def *function.Definition
 	var err error
-	registerOpts := buildRegisterOpts(opts...)
+	srcInfo := info
+	if srcInfo == nil {
+		// nil if the source is not available
+		srcInfo, _ = function.GetFunctionDetails(fn)
+	}
+	var srcHash string
+	if srcInfo != nil {
+		srcHash = function.SourceHash(srcInfo)
+	}
+	overwrite := registerOpts.Overwrite
 	if registerOpts.LoadDefDir != "" {
-		def, err = loadDefFromDisk(registerOpts.LoadDefDir, getFnName(fn))
+		var defHash string
+		def, defHash, err = LoadDef(registerOpts.LoadDefDir, fnName)
 		if os.IsNotExist(err) {
-			// create def by Definer
-			def, err = a.definer.Define(ctx, fn)
+			def, err = nil, nil
+		}
+		if def != nil && defHash != "" && srcHash != "" && defHash != srcHash {
+			switch registerOpts.StaleDef {
+			case StaleDefRegenerate:
+				// the stale file should be replaced as well
+				def = nil
+				overwrite = true
+			case StaleDefError:
+				return nil, fmt.Errorf("%w: %s in %s", StaleDefErr, fnName, registerOpts.LoadDefDir)
+			default:
+				if registerOpts.StaleDefHook != nil {
+					registerOpts.StaleDefHook(fnName, registerOpts.LoadDefDir)
+				} else {
+					log.Printf("nlcall: definition of %s in %s is stale, the function source has changed", fnName, registerOpts.LoadDefDir)
+				}
+			}
 		}
-	} else {
+	}
+	if err != nil {
+		return nil, err
+	}
+	if registerOpts.ParamNames != nil {
+		// explicit names are preferred to the source
+		named := function.NewFuncInfo(registerOpts.Name, registerOpts.Description, "", registerOpts.ParamNames, reflect.TypeOf(fn).IsVariadic())
+		if srcInfo != nil {
+			named.Name, named.Comments, named.SourceCode = srcInfo.Name, srcInfo.Comments, srcInfo.SourceCode
+		}
+		srcInfo = named
+	}
+	// injectable parameters are provided by the agent instead of LLM
+	ignoreIdx := a.injectIdx(fn)
+	if def != nil {
+		// the loaded definition may be generated before the parameters were injectable
+		def.Parameters = removeInjected(def.Parameters, srcInfo, ignoreIdx)
+	}
+	if def == nil && registerOpts.Name != "" && registerOpts.Description != "" {
+		// the Definer is not needed, which makes functions without source available
+		def, err = explicitDef(fn, srcInfo, registerOpts, ignoreIdx)
+	} else if def == nil {
 		// create def by Definer
-		def, err = a.definer.Define(ctx, fn)
+		def, err = a.define(ctx, fn, srcInfo, ignoreIdx)
 	}
 	if err != nil {
 		return nil, err
 	}
+	if registerOpts.Name != "" {
+		def.Name = registerOpts.Name
+	}
 
-	f, err := function.CreateFunction(fn, *def)
+	f, err := function.CreateFunction(fn, *def, ignoreIdx...)
 	if err != nil {
 		return nil, err
 	}
+	if srcInfo != nil {
+		f.SetFuncInfo(srcInfo)
+	}
+	f.SetTimeout(registerOpts.Timeout)
 	if err = a.RegisterFunc(f); err != nil {
 		return nil, err
 	}
+	a.addToToolsets(f.GetName(), registerOpts.Toolsets)
 	if registerOpts.SaveDefDir != "" {
-		err = saveDefToDisk(registerOpts.SaveDefDir, getFnName(fn), def, registerOpts.Overwrite)
+		err = SaveDef(registerOpts.SaveDefDir, fnName, def, srcHash, overwrite)
 		if err != nil {
 			return nil, err
 		}
@@ -146,50 +409,90 @@ func (a *Agent) RegisterFn(ctx context.Context, fn any, opts ...RegisterOption)
 	return f, nil
 }
 
-// GetFunc looks up the registered function by name
-func (a *Agent) GetFunc(funcName string) (*function.Function, error) {
-	// Look up the function
-	f, ok := a.funcMap[funcName]
-	if !ok {
-		return nil, fmt.Errorf("function %s does not exist", funcName)
+// explicitDef creates the definition of fn from the name and description given by options,
+// info is nil if the source is not available and parameters are named like arg0
+func explicitDef(fn any, info *function.FuncInfo, registerOpts RegisterOpts, ignoreIdx []int) (*function.Definition, error) {
+	var names []string
+	if info != nil {
+		names = info.ParamNames
 	}
-	return f, nil
-}
-
-const defFileSuffix = ".lcdef.json"
-
-func loadDefFromDisk(dir string, fnName string) (*function.Definition, error) {
-	path := fmt.Sprintf("%s/%s%s", dir, fnName, defFileSuffix)
-	bytes, err := os.ReadFile(path)
+	def := &function.Definition{
+		Name:        registerOpts.Name,
+		Description: registerOpts.Description,
+	}
+	schema, err := function.ParametersSchema(fn, names, ignoreIdx...)
 	if err != nil {
 		return nil, err
 	}
-	def := new(function.Definition)
-	err = json.Unmarshal(bytes, def)
+	if len(schema.Properties) > 0 {
+		def.Parameters = schema
+	}
+	return def, nil
+}
+
+// define creates the definition of fn by the Definer, info is nil if the source is not available
+func (a *Agent) define(ctx context.Context, fn any, info *function.FuncInfo, ignoreIdx []int) (*function.Definition, error) {
+	if infoDefiner, ok := a.definer.(InfoDefiner); ok && info != nil {
+		schema, err := function.ParametersSchema(fn, info.ParamNames, ignoreIdx...)
+		if err != nil {
+			return nil, err
+		}
+		return infoDefiner.DefineInfo(ctx, info, schema)
+	}
+	def, err := a.definer.Define(ctx, fn)
 	if err != nil {
 		return nil, err
 	}
+	// the Definer only knows context.Context is not a parameter
+	def.Parameters = removeInjected(def.Parameters, info, ignoreIdx)
 	return def, nil
 }
 
-func saveDefToDisk(dir string, fnName string, def *function.Definition, overwrite bool) error {
-	path := fmt.Sprintf("%s/%s%s", dir, fnName, defFileSuffix)
-	bytes, err := json.Marshal(def)
-	if err != nil {
-		return err
+// removeInjected removes the properties of the injected parameters at ignoreIdx from parameters,
+// which are kept as is if they are not a valid schema or have none of the properties
+func removeInjected(parameters any, info *function.FuncInfo, ignoreIdx []int) any {
+	if len(ignoreIdx) == 0 {
+		return parameters
 	}
-	if !overwrite {
-		if _, err = os.Stat(path); err == nil {
-			return nil
+	schema, err := function.ToSchema(parameters)
+	if err != nil || schema == nil {
+		return parameters
+	}
+	var names []string
+	if info != nil {
+		names = info.ParamNames
+	}
+	removed := false
+	for _, idx := range ignoreIdx {
+		name := function.ParamName(names, idx)
+		if _, ok := schema.Properties[name]; ok {
+			schema.RemoveProperty(name)
+			removed = true
 		}
-		err = os.WriteFile(path, bytes, 0644)
-	} else {
-		err = os.WriteFile(path, bytes, 0644)
 	}
-	if err != nil {
-		return err
+	if !removed {
+		return parameters
 	}
-	return nil
+	return schema
+}
+
+// GetFunc looks up the registered function by name
+func (a *Agent) GetFunc(funcName string) (*function.Function, error) {
+	// Look up the function
+	f, ok := a.funcMap[funcName]
+	if !ok {
+		return nil, fmt.Errorf("function %s does not exist", funcName)
+	}
+	return f, nil
+}
+
+// getPkgPath returns the package path of a runtime function name like github.com/x/y.f.func1
+func getPkgPath(fnName string) string {
+	slash := strings.LastIndex(fnName, "/")
+	if dot := strings.Index(fnName[slash+1:], "."); dot >= 0 {
+		return fnName[:slash+1+dot]
+	}
+	return fnName
 }
 
 func getFnName(fn any) string {
@@ -0,0 +1,255 @@
+package nlcall
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+// stubResolver accepts every function and resolves the user input to call
+type stubResolver struct {
+	call *function.Call
+}
+
+func (r *stubResolver) AddFunc(def *function.Function) bool {
+	return true
+}
+
+func (r *stubResolver) Resolve(ctx context.Context, userInput string) (*function.Call, error) {
+	if r.call == nil {
+		return nil, errors.New("no call")
+	}
+	return r.call, nil
+}
+
+// countingDefiner defines functions by reflection and counts the definitions
+type countingDefiner struct {
+	defined int
+}
+
+func (d *countingDefiner) Define(ctx context.Context, fn any) (*function.Definition, error) {
+	d.defined++
+	info, err := function.GetFunctionDetails(fn)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := function.ParametersSchema(fn, info.ParamNames)
+	if err != nil {
+		return nil, err
+	}
+	return &function.Definition{Name: info.Name, Description: "defined", Parameters: schema}, nil
+}
+
+func (d *countingDefiner) DefineInfo(ctx context.Context, fnInfo *function.FuncInfo, schema *function.Schema) (*function.Definition, error) {
+	d.defined++
+	return &function.Definition{Name: fnInfo.Name, Description: "defined", Parameters: schema}, nil
+}
+
+// sum returns a + b
+func sum(a, b int) int { return a + b }
+
+const sumDefName = "github.com/HFrost0/nlcall.sum"
+
+func TestRegisterFnSourceHash(t *testing.T) {
+	dir := t.TempDir()
+	definer := &countingDefiner{}
+	agent := NewAgent(&stubResolver{}, definer)
+	if _, err := agent.RegisterFn(context.Background(), sum, WithSaveDefDir(dir)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := function.GetFunctionDetails(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, hash, err := LoadDef(dir, sumDefName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != function.SourceHash(info) || def.Description != "defined" {
+		t.Errorf("LoadDef() = %v, %s", def, hash)
+	}
+
+	// the saved definition is up to date and loaded without the Definer
+	agent = NewAgent(&stubResolver{}, definer)
+	if _, err = agent.RegisterFn(context.Background(), sum, WithLoadDefDir(dir), WithStaleDef(StaleDefError)); err != nil {
+		t.Fatal(err)
+	}
+	if definer.defined != 1 {
+		t.Errorf("defined %d times, want 1", definer.defined)
+	}
+}
+
+// saveStaleDef saves a definition of sum generated for another source
+func saveStaleDef(t *testing.T, dir string) {
+	def := &function.Definition{Name: "sum", Description: "stale"}
+	if err := SaveDef(dir, sumDefName, def, "other", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterFnStaleDef(t *testing.T) {
+	t.Run("warn", func(t *testing.T) {
+		dir := t.TempDir()
+		saveStaleDef(t, dir)
+		var stale []string
+		agent := NewAgent(&stubResolver{}, &countingDefiner{})
+		f, err := agent.RegisterFn(context.Background(), sum, WithLoadDefDir(dir),
+			WithStaleDefHook(func(fnName string, dir string) { stale = append(stale, fnName) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.GetDef().Description != "stale" {
+			t.Errorf("description = %s, want the stale one", f.GetDef().Description)
+		}
+		if len(stale) != 1 || stale[0] != sumDefName {
+			t.Errorf("stale hook called with %v", stale)
+		}
+	})
+	t.Run("warn without hook", func(t *testing.T) {
+		dir := t.TempDir()
+		saveStaleDef(t, dir)
+		buf := new(bytes.Buffer)
+		log.SetOutput(buf)
+		defer log.SetOutput(os.Stderr)
+		agent := NewAgent(&stubResolver{}, &countingDefiner{})
+		if _, err := agent.RegisterFn(context.Background(), sum, WithLoadDefDir(dir)); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), sumDefName+" in "+dir+" is stale") {
+			t.Errorf("log = %q", buf.String())
+		}
+	})
+	t.Run("regenerate", func(t *testing.T) {
+		dir := t.TempDir()
+		saveStaleDef(t, dir)
+		definer := &countingDefiner{}
+		agent := NewAgent(&stubResolver{}, definer)
+		f, err := agent.RegisterFn(context.Background(), sum, WithLoadDefDir(dir), WithSaveDefDir(dir),
+			WithStaleDef(StaleDefRegenerate))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.GetDef().Description != "defined" || definer.defined != 1 {
+			t.Errorf("description = %s, defined %d times", f.GetDef().Description, definer.defined)
+		}
+		// the stale file is replaced even without WithOverwrite
+		def, hash, err := LoadDef(dir, sumDefName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if def.Description != "defined" || hash == "other" {
+			t.Errorf("LoadDef() = %v, %s", def, hash)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		dir := t.TempDir()
+		saveStaleDef(t, dir)
+		agent := NewAgent(&stubResolver{}, &countingDefiner{})
+		_, err := agent.RegisterFn(context.Background(), sum, WithLoadDefDir(dir), WithStaleDef(StaleDefError))
+		if !errors.Is(err, StaleDefErr) {
+			t.Errorf("RegisterFn() error = %v, want StaleDefErr", err)
+		}
+	})
+	t.Run("no hash", func(t *testing.T) {
+		// definitions saved before hashes were stored are never stale
+		dir := t.TempDir()
+		if err := SaveDef(dir, sumDefName, &function.Definition{Name: "sum", Description: "old"}, "", true); err != nil {
+			t.Fatal(err)
+		}
+		agent := NewAgent(&stubResolver{}, &countingDefiner{})
+		f, err := agent.RegisterFn(context.Background(), sum, WithLoadDefDir(dir), WithStaleDef(StaleDefError))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.GetDef().Description != "old" {
+			t.Errorf("description = %s, want old", f.GetDef().Description)
+		}
+	})
+}
+
+type calculator struct{}
+
+// Add returns a + b
+func (calculator) Add(a, b int) int { return a + b }
+
+// Neg returns -a
+func (calculator) Neg(a int) int { return -a }
+
+// lockedCalculator has the methods of sync.Mutex and calculator promoted
+type lockedCalculator struct {
+	sync.Mutex
+	calculator
+}
+
+// Abs returns |a|
+func (*lockedCalculator) Abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func TestRegisterObject(t *testing.T) {
+	agent := NewAgent(&stubResolver{}, &countingDefiner{})
+	fs, err := agent.RegisterObject(context.Background(), calculator{}, WithNamespace("calc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range fs {
+		names = append(names, f.GetName())
+	}
+	if !reflect.DeepEqual(names, []string{"calc.Add", "calc.Neg"}) {
+		t.Errorf("RegisterObject() = %v", names)
+	}
+	if toolset := agent.Toolset("calc"); !reflect.DeepEqual(toolset, names) {
+		t.Errorf("Toolset() = %v, want %v", toolset, names)
+	}
+
+	// only the methods declared by the type are registered
+	agent = NewAgent(&stubResolver{}, &countingDefiner{})
+	if fs, err = agent.RegisterObject(context.Background(), &lockedCalculator{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 1 || fs[0].GetName() != "lockedCalculator.Abs" {
+		t.Errorf("RegisterObject() = %v", fs)
+	}
+	for _, name := range []string{"lockedCalculator.Lock", "lockedCalculator.Unlock", "lockedCalculator.Add"} {
+		if _, err = agent.GetFunc(name); err == nil {
+			t.Errorf("promoted method %s is registered", name)
+		}
+	}
+}
+
+func TestInvokeTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	wait := func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	}
+	agent := NewAgent(&stubResolver{call: &function.Call{Name: "wait", Params: &function.Params{}}}, nil)
+	if _, err := agent.RegisterFn(context.Background(), wait, WithName("wait"), WithDescription("wait"),
+		WithTimeout(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := agent.Call(context.Background(), "wait")
+	var timeoutErr TimeoutErr
+	if !errors.As(err, &timeoutErr) {
+		t.Errorf("Call() error = %v, want TimeoutErr", err)
+	}
+	// the injected context is cancelled by the timeout of the function
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("the injected context is not cancelled")
+	}
+}
@@ -0,0 +1,30 @@
+package nlcall
+
+import (
+	"context"
+	"github.com/HFrost0/nlcall/function"
+)
+
+// Candidate is a call that the user input may be resolved to,
+// Score in [0, 1] is the confidence of the model in the call
+type Candidate struct {
+	Call  *function.Call
+	Score float64
+}
+
+// AssignCandidates resolves the user input to at most k candidate calls in descending order of their scores,
+// so that the alternatives can be shown or a call with a low score can be confirmed before it is made by Invoke.
+// The user input is resolved to a single candidate with score 1 if the resolver doesn't implement CandidateResolver
+func (a *Agent) AssignCandidates(ctx context.Context, userInput string, k int) ([]*Candidate, error) {
+	if userInput == "" {
+		return nil, EmptyUserInputErr
+	}
+	if candidateResolver, ok := a.resolver.(CandidateResolver); ok {
+		return candidateResolver.ResolveCandidates(ctx, userInput, k)
+	}
+	call, err := a.resolver.Resolve(ctx, userInput)
+	if err != nil {
+		return nil, err
+	}
+	return []*Candidate{{Call: call, Score: 1}}, nil
+}
@@ -0,0 +1,74 @@
+// nlcall-gen precomputes the function.FuncInfo of the functions in a package,
+// so that binaries can be registered without shipping the source files.
+//
+// Annotate the functions with a //nlcall:func directive and add
+//
+//	//go:generate go run github.com/HFrost0/nlcall/cmd/nlcall-gen
+//
+// to one file of the package, the generated file registers the FuncInfo in init.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HFrost0/nlcall/internal/source"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "the package dir to parse")
+	out := flag.String("o", "nlcall_funcinfo.go", "the output file name, relative to dir")
+	all := flag.Bool("all", false, "include all top level functions instead of the annotated ones")
+	flag.Parse()
+
+	src, err := generate(*dir, filepath.Base(*out), *all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(*dir, *out), src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate parses the package in dir and returns the source of the registering file
+func generate(dir string, outName string, all bool) ([]byte, error) {
+	pkg, err := source.Parse(dir, func(name string) bool {
+		return name == outName
+	}, all)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by nlcall-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(&buf, "import \"github.com/HFrost0/nlcall/function\"\n\n")
+	fmt.Fprintf(&buf, "func init() {\n")
+	for _, fn := range pkg.Funcs {
+		info := fn.Info
+		fmt.Fprintf(&buf, "function.RegisterFuncInfo(%s, function.NewFuncInfo(\n", fn.Expr())
+		fmt.Fprintf(&buf, "%s,\n", strconv.Quote(info.Name))
+		fmt.Fprintf(&buf, "%s,\n", strconv.Quote(info.Comments))
+		fmt.Fprintf(&buf, "%s,\n", strconv.Quote(info.SourceCode))
+		fmt.Fprintf(&buf, "%s,\n", stringSlice(info.ParamNames))
+		fmt.Fprintf(&buf, "%t,\n", info.Variadic)
+		fmt.Fprintf(&buf, "))\n")
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return format.Source(buf.Bytes())
+}
+
+func stringSlice(ss []string) string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = strconv.Quote(s)
+	}
+	return fmt.Sprintf("[]string{%s}", strings.Join(quoted, ", "))
+}
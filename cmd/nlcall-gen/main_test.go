@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSrc = `package calc
+
+// add returns the sum of nums
+//
+//nlcall:func
+func add(base int, nums ...int) int {
+	for _, n := range nums {
+		base += n
+	}
+	return base
+}
+
+func sub(a, b int) int { return a - b }
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "calc.go"), []byte(testSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := generate(dir, "nlcall_funcinfo.go", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"package calc",
+		`function.RegisterFuncInfo(add, function.NewFuncInfo(`,
+		`"// add returns the sum of nums",`,
+		`[]string{"base", "nums"}`,
+		"true,",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generate() output missing %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "sub") {
+		t.Errorf("generate() should only include annotated functions:\n%s", got)
+	}
+
+	out, err = generate(dir, "nlcall_funcinfo.go", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "function.RegisterFuncInfo(sub,") {
+		t.Errorf("generate() with all should include sub:\n%s", out)
+	}
+}
@@ -0,0 +1,226 @@
+// nlcall manages the function definitions ahead of time so that they can be reviewed and committed like code.
+//
+//	nlcall defs generate -dir . -out ./fn_def -url http://127.0.0.1:1234/v1/chat/completions -model qwen2.5-14b-instruct
+//	nlcall defs check -dir . -out ./fn_def
+//
+// Functions annotated with //nlcall:func are processed, use -all for every top level function.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HFrost0/nlcall"
+	"github.com/HFrost0/nlcall/function"
+	"github.com/HFrost0/nlcall/internal/source"
+	"github.com/HFrost0/nlcall/llm"
+	"github.com/HFrost0/nlcall/llm/openai"
+)
+
+const usage = `usage:
+	nlcall defs generate [flags]	generate the missing or stale definition files by LLM
+	nlcall defs check [flags]	report missing or stale definition files
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "defs" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[2] {
+	case "generate":
+		err = runGenerate(os.Args[3:])
+	case "check":
+		err = runCheck(os.Args[3:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type commonFlags struct {
+	dir     string
+	out     string
+	all     bool
+	pkgPath string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.dir, "dir", ".", "the package dir to parse")
+	fs.StringVar(&c.out, "out", "./fn_def", "the dir of definition files")
+	fs.BoolVar(&c.all, "all", false, "include all top level functions instead of the annotated ones")
+	fs.StringVar(&c.pkgPath, "pkgpath", "", "the package path used in definition file names, detected from go.mod by default")
+}
+
+// load parses the package and returns it with its path
+func (c *commonFlags) load() (*source.Package, string, error) {
+	pkg, err := source.Parse(c.dir, nil, c.all)
+	if err != nil {
+		return nil, "", err
+	}
+	pkgPath := c.pkgPath
+	if pkgPath == "" {
+		if pkgPath, err = detectPkgPath(c.dir, pkg.Name); err != nil {
+			return nil, "", err
+		}
+	}
+	return pkg, pkgPath, nil
+}
+
+func runGenerate(args []string) error {
+	var c commonFlags
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	c.register(fs)
+	url := fs.String("url", "http://127.0.0.1:1234/v1/chat/completions", "the OpenAI compatible chat completion endpoint")
+	model := fs.String("model", "", "the model name")
+	overwrite := fs.Bool("overwrite", false, "overwrite the existing definition files")
+	_ = fs.Parse(args)
+
+	pkg, pkgPath, err := c.load()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(c.out, 0755); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	definer := llm.NewDefiner(openai.NewClient(*url, *model))
+	for _, fn := range pkg.Funcs {
+		fnName := pkgPath + "." + fn.DefName()
+		schema := pkg.ParametersSchema(fn)
+		if !*overwrite {
+			// up to date definitions are kept
+			if def, hash, err := nlcall.LoadDef(c.out, fnName); err == nil && staleReason(def, hash, fn.Info, schema) == "" {
+				continue
+			}
+		}
+		def, err := definer.DefineInfo(ctx, fn.Info, schema)
+		if err != nil {
+			return fmt.Errorf("define %s: %w", fnName, err)
+		}
+		if fn.Recv != "" {
+			// methods are registered by Agent.RegisterObject with namespaced names
+			def.Name = fn.DefName()
+		}
+		if err = nlcall.SaveDef(c.out, fnName, def, function.SourceHash(fn.Info), true); err != nil {
+			return err
+		}
+		fmt.Printf("generated %s\n", fnName)
+	}
+	return nil
+}
+
+func runCheck(args []string) error {
+	var c commonFlags
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	c.register(fs)
+	_ = fs.Parse(args)
+
+	pkg, pkgPath, err := c.load()
+	if err != nil {
+		return err
+	}
+	problems := 0
+	for _, fn := range pkg.Funcs {
+		fnName := pkgPath + "." + fn.DefName()
+		def, hash, err := nlcall.LoadDef(c.out, fnName)
+		if os.IsNotExist(err) {
+			fmt.Printf("missing %s\n", fnName)
+			problems++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if reason := staleReason(def, hash, fn.Info, pkg.ParametersSchema(fn)); reason != "" {
+			fmt.Printf("stale   %s: %s\n", fnName, reason)
+			problems++
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d of %d definitions need to be generated", problems, len(pkg.Funcs))
+	}
+	return nil
+}
+
+// staleReason compares a definition with the current function, "" means up to date.
+// Definitions without source hash are compared by parameter names.
+func staleReason(def *function.Definition, hash string, info *function.FuncInfo, schema *function.Schema) string {
+	if hash != "" {
+		if hash != function.SourceHash(info) {
+			return "source changed"
+		}
+		return ""
+	}
+	want := propertyNames(schema)
+	got := propertyNames(def.Parameters)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		return fmt.Sprintf("parameters [%s] changed to [%s]", strings.Join(got, ", "), strings.Join(want, ", "))
+	}
+	return ""
+}
+
+func propertyNames(parameters any) []string {
+	var s function.Schema
+	if b, err := json.Marshal(parameters); err == nil {
+		_ = json.Unmarshal(b, &s)
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectPkgPath returns the package path as reported by runtime, "main" for main packages
+func detectPkgPath(dir string, pkgName string) (string, error) {
+	if pkgName == "main" {
+		return "main", nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			module := modulePath(data)
+			if module == "" {
+				return "", fmt.Errorf("no module path in %s", filepath.Join(root, "go.mod"))
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			if rel == "." {
+				return module, nil
+			}
+			return module + "/" + filepath.ToSlash(rel), nil
+		}
+		if filepath.Dir(root) == root {
+			return "", fmt.Errorf("go.mod not found for %s, use -pkgpath", dir)
+		}
+	}
+}
+
+func modulePath(goMod []byte) string {
+	for _, line := range strings.Split(string(goMod), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "module ") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`)
+		}
+	}
+	return ""
+}
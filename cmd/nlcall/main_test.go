@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+func TestStaleReason(t *testing.T) {
+	info := function.NewFuncInfo("add", "", "func add(a, b int) int { return a + b }", []string{"a", "b"}, false)
+	schema, err := function.ParametersSchema(func(a, b int) int { return a + b }, info.ParamNames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed, err := function.ParametersSchema(func(x, y int) int { return x + y }, []string{"x", "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		params any
+		hash   string
+		want   string
+	}{
+		{"same hash", renamed, function.SourceHash(info), ""},
+		{"other hash", schema, "other", "source changed"},
+		{"no hash same params", schema, "", ""},
+		{"no hash renamed params", renamed, "", "parameters [x, y] changed to [a, b]"},
+		{"no hash decoded params", map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"b": map[string]any{"type": "integer"}, "a": map[string]any{"type": "integer"}},
+		}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := &function.Definition{Name: "add", Parameters: tt.params}
+			if got := staleReason(def, tt.hash, info, schema); got != tt.want {
+				t.Errorf("staleReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectPkgPath(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/shop\n\ngo 1.18\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "internal", "billing"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		dir     string
+		pkgName string
+		want    string
+	}{
+		{"module root", root, "shop", "example.com/shop"},
+		{"nested", filepath.Join(root, "internal", "billing"), "billing", "example.com/shop/internal/billing"},
+		{"main", filepath.Join(root, "internal", "billing"), "main", "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectPkgPath(tt.dir, tt.pkgName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("detectPkgPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := detectPkgPath(os.TempDir(), "orphan"); err == nil || !strings.Contains(err.Error(), "go.mod not found") {
+		t.Errorf("detectPkgPath() without go.mod error = %v", err)
+	}
+}
+
+func TestDetectPkgPathRuntime(t *testing.T) {
+	// the path is the one of the function names reported by runtime, which name the def files of RegisterFn
+	got, err := detectPkgPath(filepath.Join("..", "..", "function"), "function")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fnName := runtime.FuncForPC(reflect.ValueOf(function.CreateFunction).Pointer()).Name()
+	if want := strings.TrimSuffix(fnName, ".CreateFunction"); got != want {
+		t.Errorf("detectPkgPath() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package nlcall
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HFrost0/nlcall/function"
+	"os"
+	"strings"
+)
+
+const defFileSuffix = ".lcdef.json"
+
+// defFile is the content of a definition file, the source hash is kept out of function.Definition
+// since the definition is sent to LLM as it is
+type defFile struct {
+	*function.Definition
+	SourceHash string `json:"source_hash,omitempty"` // function.SourceHash of the defined function
+}
+
+// LoadDef loads the function definition of fnName from dir with the source hash it was generated for,
+// the hash is empty for definition files written before hashes were stored
+func LoadDef(dir string, fnName string) (def *function.Definition, sourceHash string, err error) {
+	path := defPath(dir, fnName)
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	file := &defFile{Definition: new(function.Definition)}
+	err = json.Unmarshal(bytes, file)
+	if err != nil {
+		return nil, "", err
+	}
+	return file.Definition, file.SourceHash, nil
+}
+
+// SaveDef saves the function definition of fnName to dir, an existing file is kept unless overwrite
+func SaveDef(dir string, fnName string, def *function.Definition, sourceHash string, overwrite bool) error {
+	path := defPath(dir, fnName)
+	bytes, err := json.Marshal(&defFile{Definition: def, SourceHash: sourceHash})
+	if err != nil {
+		return err
+	}
+	if !overwrite {
+		if _, err = os.Stat(path); err == nil {
+			return nil
+		}
+		err = os.WriteFile(path, bytes, 0644)
+	} else {
+		err = os.WriteFile(path, bytes, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// defPath returns the def file path, the slashes of package paths like github.com/x/y.Func are replaced
+func defPath(dir string, fnName string) string {
+	return fmt.Sprintf("%s/%s%s", dir, strings.ReplaceAll(fnName, "/", "_"), defFileSuffix)
+}
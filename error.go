@@ -1,17 +1,44 @@
 package nlcall
 
-import "errors"
+import (
+	"errors"
+	"github.com/HFrost0/nlcall/function"
+)
 
 var (
 	EmptyUserInputErr = errors.New("empty user input")
+	StaleDefErr       = errors.New("stale function definition")
+	MaxStepsErr       = errors.New("max steps exceeded")
 )
 
 type FuncCreateErr struct {
 	Msg string
 }
 
-type FuncCallErr struct {
-	Msg string
+// FuncCallErr is returned when the called function fails
+type FuncCallErr = function.FuncCallErr
+
+// PanicErr is returned when the called function panics
+type PanicErr = function.PanicErr
+
+// ValidationErr is returned when the arguments don't match the parameters schema
+type ValidationErr = function.ValidationErr
+
+// TimeoutErr is returned when the called function doesn't return before the deadline
+type TimeoutErr = function.TimeoutErr
+
+// CanceledErr is returned when the context is cancelled before the called function returns
+type CanceledErr = function.CanceledErr
+
+// NoSuitableFuncErr is returned when none of the functions is suitable for the user input,
+// Reply is the text reply of the model so that the caller can fall back to chat
+type NoSuitableFuncErr struct {
+	Reply string
+}
+
+// FuncNotAllowedErr is returned when the user input is resolved to a function out of the allowed ones
+type FuncNotAllowedErr struct {
+	Name string
 }
 
 type FuncStrParseErr struct {
@@ -22,8 +49,15 @@ func (e FuncCreateErr) Error() string {
 	return e.Msg
 }
 
-func (e FuncCallErr) Error() string {
-	return e.Msg
+func (e NoSuitableFuncErr) Error() string {
+	if e.Reply == "" {
+		return "no suitable function"
+	}
+	return "no suitable function: " + e.Reply
+}
+
+func (e FuncNotAllowedErr) Error() string {
+	return "function " + e.Name + " is not allowed"
 }
 
 func (e FuncStrParseErr) Error() string {
@@ -4,10 +4,12 @@ import (
 	"fmt"
 )
 
+//nlcall:func
 func greet(name string, age int) string {
 	return fmt.Sprintf("Hello, %s! You are %d years old.", name, age)
 }
 
+//nlcall:func
 func add(nums []int) int {
 	sum := 0
 	for _, num := range nums {
@@ -16,15 +18,14 @@ func add(nums []int) int {
 	return sum
 }
 
-func no() string {
-	return "Sorry, I can't help you with that."
-}
-
 // weather is a function that returns the weather in a city
+//
+//nlcall:func
 func weather(city string) string {
 	return fmt.Sprintf("The weather in %s is sunny.", city)
 }
 
+//nlcall:func
 func lengthOfLongestSubstring(s string) int {
 	if len(s) <= 1 {
 		return len(s)
@@ -47,6 +48,7 @@ func lengthOfLongestSubstring(s string) int {
 	return res
 }
 
+//nlcall:func
 func mul(nums []int) int {
 	res := 1
 	for _, num := range nums {
@@ -2,21 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/HFrost0/nlcall"
 	"github.com/HFrost0/nlcall/llm"
+	"github.com/HFrost0/nlcall/llm/openai"
 	"log"
 )
 
+//go:generate go run github.com/HFrost0/nlcall/cmd/nlcall-gen
+
 const dir = "./fn_def"
 
 func main() {
 	ctx := context.Background()
-	client := NewLmClient("http://127.0.0.1:1234/v1/chat/completions", "qwen2.5-14b-instruct")
+	client := openai.NewClient("http://127.0.0.1:1234/v1/chat/completions", "qwen2.5-14b-instruct")
 
 	agent := llm.NewLlmAgent(client)
 	for _, f := range []any{
-		add, greet, weather, lengthOfLongestSubstring, mul, no,
+		add, greet, weather, lengthOfLongestSubstring, mul,
 	} {
 		if _, err := agent.RegisterFn(ctx, f, nlcall.WithLoadDefDir(dir)); err != nil {
 			log.Fatal(err)
@@ -26,6 +30,28 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	res := fn()
+	res, err := fn.SafeCall()
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Println(res)
+
+	// fall back to chat if none of the functions is suitable
+	if _, err = agent.AssignCallable(ctx, "tell me a joke"); err != nil {
+		var noSuitableErr nlcall.NoSuitableFuncErr
+		if !errors.As(err, &noSuitableErr) {
+			log.Fatal(err)
+		}
+		fmt.Println(noSuitableErr.Reply)
+	}
+
+	// call functions until the model answers
+	runRes, err := agent.Run(ctx, "what's the weather in Beijing and Shanghai?")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, step := range runRes.Steps {
+		fmt.Println(step.Call.Name, step.Result, step.Err)
+	}
+	fmt.Println(runRes.Answer)
 }
@@ -0,0 +1,43 @@
+// Code generated by nlcall-gen. DO NOT EDIT.
+
+package main
+
+import "github.com/HFrost0/nlcall/function"
+
+func init() {
+	function.RegisterFuncInfo(add, function.NewFuncInfo(
+		"add",
+		"",
+		"func add(nums []int) int {\n\tsum := 0\n\tfor _, num := range nums {\n\t\tsum += num\n\t}\n\treturn sum\n}",
+		[]string{"nums"},
+		false,
+	))
+	function.RegisterFuncInfo(greet, function.NewFuncInfo(
+		"greet",
+		"",
+		"func greet(name string, age int) string {\n\treturn fmt.Sprintf(\"Hello, %s! You are %d years old.\", name, age)\n}",
+		[]string{"name", "age"},
+		false,
+	))
+	function.RegisterFuncInfo(lengthOfLongestSubstring, function.NewFuncInfo(
+		"lengthOfLongestSubstring",
+		"",
+		"func lengthOfLongestSubstring(s string) int {\n\tif len(s) <= 1 {\n\t\treturn len(s)\n\t}\n\tm := make(map[byte]struct{})\n\ti, j := 0, 0\n\tres := 0\n\tm[s[i]] = struct{}{}\n\tm[s[j]] = struct{}{}\n\tfor j < len(s)-1 {\n\t\tif _, ok := m[s[j+1]]; !ok {\n\t\t\tj += 1\n\t\t\tres = max(res, j-i+1)\n\t\t\tm[s[j]] = struct{}{}\n\t\t} else {\n\t\t\tdelete(m, s[i])\n\t\t\ti += 1\n\t\t}\n\t}\n\treturn res\n}",
+		[]string{"s"},
+		false,
+	))
+	function.RegisterFuncInfo(mul, function.NewFuncInfo(
+		"mul",
+		"",
+		"func mul(nums []int) int {\n\tres := 1\n\tfor _, num := range nums {\n\t\tres *= num\n\t}\n\treturn res\n}",
+		[]string{"nums"},
+		false,
+	))
+	function.RegisterFuncInfo(weather, function.NewFuncInfo(
+		"weather",
+		"// weather is a function that returns the weather in a city",
+		"func weather(city string) string {\n\treturn fmt.Sprintf(\"The weather in %s is sunny.\", city)\n}",
+		[]string{"city"},
+		false,
+	))
+}
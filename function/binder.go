@@ -0,0 +1,191 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParamNames returns the names of all parameters by position,
+// the names come from source and fall back to arg<idx> as in ParametersSchema
+func (f *Function) ParamNames() []string {
+	ft := f.funcValue.Type()
+	var srcNames []string
+	if info, err := f.GetOrGenFuncInfo(); err == nil {
+		srcNames = info.ParamNames
+	}
+	names := make([]string, ft.NumIn())
+	for i := range names {
+		names[i] = ParamName(srcNames, i)
+	}
+	return names
+}
+
+// BindArgs binds a named json argument object like {"a":1,"b":2} to positional Params,
+// ignored parameters are skipped and the variadic parameter takes a json array.
+// Missing and unknown arguments are reported as ValidationErr, the values are validated by GetCallable.
+func (f *Function) BindArgs(args string) (*Params, error) {
+	if f.IsStructParam() {
+		return NewStructParams(args), nil
+	}
+	named := make(map[string]json.RawMessage)
+	if s := strings.TrimSpace(args); s != "" && s != "null" {
+		if err := json.Unmarshal([]byte(s), &named); err != nil {
+			return nil, fmt.Errorf("invalid arguments for function %s: %w", f.GetName(), err)
+		}
+	}
+	return f.bind(named)
+}
+
+func (f *Function) bind(named map[string]json.RawMessage) (*Params, error) {
+	ft := f.funcValue.Type()
+	ignoreIdxMap := make(map[int]bool)
+	for _, idx := range f.ignoreIdx {
+		ignoreIdxMap[idx] = true
+	}
+	names := f.ParamNames()
+	used := make(map[string]bool)
+	var errs []ParamErr
+	params := &Params{RawParams: make([]string, 0, len(names)-len(f.ignoreIdx))}
+	for i, name := range names {
+		if ignoreIdxMap[i] {
+			continue
+		}
+		raw, ok := named[name]
+		if !ok {
+			if i == ft.NumIn()-1 && ft.IsVariadic() {
+				// no variadic arguments
+				params.RawParams = append(params.RawParams, "[]")
+				continue
+			}
+			errs = append(errs, ParamErr{Path: name, Msg: "missing required property"})
+			continue
+		}
+		used[name] = true
+		params.RawParams = append(params.RawParams, string(raw))
+	}
+	var unknown []string
+	for name := range named {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, ParamErr{Path: name, Msg: "unknown property"})
+	}
+	if len(errs) > 0 {
+		return nil, ValidationErr{Name: f.GetName(), Errors: errs}
+	}
+	return params, nil
+}
+
+// NewExprParams creates Params of the argument expressions, which are RawParams if there is no nested call
+func NewExprParams(args []*Expr) *Params {
+	if AnyNested(args) {
+		return &Params{Exprs: args}
+	}
+	rawParams := make([]string, len(args))
+	for i, arg := range args {
+		rawParams[i] = arg.JSON
+	}
+	return &Params{RawParams: rawParams}
+}
+
+// BindExprs binds the arguments of a calling string like greet("jack", age=14) to positional Params,
+// names[i] is the keyword of args[i] or "" for a positional argument.
+// Positional arguments fill the parameters in order and keyword arguments are bound by name as BindArgs,
+// a struct parameter takes the keyword arguments as its fields.
+func (f *Function) BindExprs(args []*Expr, names []string) (*Params, error) {
+	nPositional := len(names)
+	for i, name := range names {
+		if name != "" && nPositional == len(names) {
+			nPositional = i
+		} else if name == "" && nPositional < len(names) {
+			return nil, fmt.Errorf("positional argument %d follows keyword argument for function %s", i, f.GetName())
+		}
+	}
+	if nPositional == len(names) {
+		return NewExprParams(args), nil
+	}
+	var errs []ParamErr
+	if f.IsStructParam() {
+		if nPositional > 0 {
+			return nil, fmt.Errorf("positional and keyword arguments are mixed for the struct parameter of function %s", f.GetName())
+		}
+		seen := make(map[string]bool)
+		for _, name := range names {
+			if seen[name] {
+				errs = append(errs, ParamErr{Path: name, Msg: "duplicated argument"})
+			}
+			seen[name] = true
+		}
+		if len(errs) > 0 {
+			return nil, ValidationErr{Name: f.GetName(), Errors: errs}
+		}
+		fields := make([]*Field, len(args))
+		for i, arg := range args {
+			fields[i] = &Field{Key: names[i], Value: arg}
+		}
+		obj := &Expr{Fields: fields}
+		if !AnyNested(args) {
+			// evaluated without nested calls
+			v, _ := obj.Eval(nil)
+			obj = &Expr{JSON: v}
+		}
+		return NewExprParams([]*Expr{obj}), nil
+	}
+
+	ft := f.funcValue.Type()
+	ignoreIdxMap := make(map[int]bool)
+	for _, idx := range f.ignoreIdx {
+		ignoreIdxMap[idx] = true
+	}
+	var positions []int // indexes of the parameters taken from the calling string
+	for i := 0; i < ft.NumIn(); i++ {
+		if !ignoreIdxMap[i] {
+			positions = append(positions, i)
+		}
+	}
+	if len(args) > len(positions) {
+		return nil, fmt.Errorf("parameter count mismatch for function %s", f.GetName())
+	}
+	paramNames := f.ParamNames()
+	bound := make([]*Expr, len(positions))
+	for i, arg := range args {
+		if names[i] == "" {
+			bound[i] = arg
+			continue
+		}
+		j := 0
+		for j < len(positions) && paramNames[positions[j]] != names[i] {
+			j++
+		}
+		switch {
+		case j == len(positions):
+			errs = append(errs, ParamErr{Path: names[i], Msg: "unknown argument"})
+		case j < nPositional:
+			errs = append(errs, ParamErr{Path: names[i], Msg: "duplicated argument, which is also given by position"})
+		case bound[j] != nil:
+			errs = append(errs, ParamErr{Path: names[i], Msg: "duplicated argument"})
+		default:
+			bound[j] = arg
+		}
+	}
+	for j, arg := range bound {
+		if arg != nil {
+			continue
+		}
+		if positions[j] == ft.NumIn()-1 && ft.IsVariadic() {
+			// no variadic arguments
+			bound[j] = &Expr{JSON: "[]"}
+			continue
+		}
+		errs = append(errs, ParamErr{Path: paramNames[positions[j]], Msg: "missing argument"})
+	}
+	if len(errs) > 0 {
+		return nil, ValidationErr{Name: f.GetName(), Errors: errs}
+	}
+	return NewExprParams(bound), nil
+}
@@ -0,0 +1,135 @@
+package function
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func describe(ctx context.Context, name string, x, y int, _ bool, tags ...string) string {
+	return fmt.Sprintf("%s:%d,%d:%v", name, x, y, tags)
+}
+
+func TestGetFunctionDetailsParamIndex(t *testing.T) {
+	info, err := GetFunctionDetails(describe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ctx", "name", "x", "y", "_", "tags"}; !reflect.DeepEqual(info.ParamNames, want) {
+		t.Errorf("ParamNames = %v, want %v", info.ParamNames, want)
+	}
+	want := map[string]ParamInfo{
+		"ctx":  {Name: "ctx", Index: 0},
+		"name": {Name: "name", Index: 1},
+		"x":    {Name: "x", Index: 2},
+		"y":    {Name: "y", Index: 3},
+		"tags": {Name: "tags", Index: 5, Variadic: true},
+	}
+	if !reflect.DeepEqual(info.Params, want) {
+		t.Errorf("Params = %v, want %v", info.Params, want)
+	}
+}
+
+func TestBindArgs(t *testing.T) {
+	f, err := CreateFunction(describe, Definition{Name: "describe"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{"ordered", `{"tags":["a","b"],"arg4":true,"y":2,"x":1,"name":"p"}`, []string{`"p"`, "1", "2", "true", `["a","b"]`}, false},
+		{"no variadic", `{"y":2,"x":1,"name":"p","arg4":false}`, []string{`"p"`, "1", "2", "false", "[]"}, false},
+		{"missing", `{"name":"p","x":1,"arg4":false}`, nil, true},
+		{"unknown", `{"name":"p","x":1,"y":2,"arg4":false,"z":3}`, nil, true},
+		{"invalid", `[1,2]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := f.BindArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BindArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(p.RawParams, tt.want) {
+				t.Errorf("BindArgs() = %v, want %v", p.RawParams, tt.want)
+			}
+		})
+	}
+
+	p, err := f.BindArgs(`{"name":"p","x":1,"y":2,"arg4":true,"tags":["a"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.Call(p, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0] != "p:1,2:[a]" {
+		t.Errorf("Call() = %v", res[0])
+	}
+}
+
+func TestBindExprs(t *testing.T) {
+	f, err := CreateFunction(describe, Definition{Name: "describe"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lit := func(vs ...string) []*Expr {
+		exprs := make([]*Expr, len(vs))
+		for i, v := range vs {
+			exprs[i] = &Expr{JSON: v}
+		}
+		return exprs
+	}
+	tests := []struct {
+		name    string
+		args    []*Expr
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{"positional", lit(`"p"`, "1", "2", "true"), []string{"", "", "", ""}, []string{`"p"`, "1", "2", "true"}, ""},
+		{"keyword", lit("2", "1", `"p"`, "false", `["a"]`), []string{"y", "x", "name", "arg4", "tags"}, []string{`"p"`, "1", "2", "false", `["a"]`}, ""},
+		{"mixed", lit(`"p"`, "1", "true", "2"), []string{"", "", "arg4", "y"}, []string{`"p"`, "1", "2", "true", "[]"}, ""},
+		{"unknown", lit(`"p"`, "1", "2", "true", "3"), []string{"", "", "", "", "z"}, nil, "z: unknown argument"},
+		{"duplicated", lit(`"p"`, "1", "2", "true", `"q"`), []string{"", "", "", "", "name"}, nil, "name: duplicated argument, which is also given by position"},
+		{"twice", lit(`"p"`, "1", "2", "true", "3"), []string{"", "", "y", "arg4", "y"}, nil, "y: duplicated argument"},
+		{"missing", lit(`"p"`, "1"), []string{"name", "x"}, nil, "y: missing argument"},
+		{"positional after keyword", lit(`"p"`, "1"), []string{"name", ""}, nil, "positional argument 1 follows keyword argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := f.BindExprs(tt.args, tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("BindExprs() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(p.RawParams, tt.want) {
+				t.Errorf("BindExprs() = %v, want %v", p.RawParams, tt.want)
+			}
+		})
+	}
+
+	// keyword arguments are the fields of a struct parameter
+	search, err := CreateFunction(func(req SearchRequest) string { return req.Query }, Definition{Name: "search"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := search.BindExprs(lit(`"go"`, `["en"]`), []string{"query", "langs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`{"query":"go","langs":["en"]}`}; !reflect.DeepEqual(p.RawParams, want) {
+		t.Errorf("BindExprs() = %v, want %v", p.RawParams, want)
+	}
+}
@@ -0,0 +1,92 @@
+package function
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Expr is an argument expression of a Call, a json value which may contain nested calls
+// like add([1,2]) in mul([add([1,2]),3]). Exactly one of JSON, Call, Items or Fields is set
+type Expr struct {
+	JSON   string   // a json value without nested calls
+	Call   *Call    // a nested call whose result is the value
+	Items  []*Expr  // a json array with nested calls
+	Fields []*Field // a json object with nested calls
+}
+
+// Field is a key-value pair of a json object expression
+type Field struct {
+	Key   string
+	Value *Expr
+}
+
+// IsNested reports whether the expression contains nested calls
+func (e *Expr) IsNested() bool {
+	return e.JSON == ""
+}
+
+// AnyNested reports whether any of the expressions contains nested calls
+func AnyNested(exprs []*Expr) bool {
+	for _, e := range exprs {
+		if e.IsNested() {
+			return true
+		}
+	}
+	return false
+}
+
+// Eval evaluates the expression bottom-up to a json value,
+// eval makes a nested call whose arguments are already evaluated and returns its json result
+func (e *Expr) Eval(eval func(call *Call) (string, error)) (string, error) {
+	switch {
+	case e.Call != nil:
+		params, err := e.Call.Params.Eval(eval)
+		if err != nil {
+			return "", err
+		}
+		return eval(&Call{Name: e.Call.Name, Params: params})
+	case e.Items != nil:
+		items := make([]string, len(e.Items))
+		for i, item := range e.Items {
+			v, err := item.Eval(eval)
+			if err != nil {
+				return "", err
+			}
+			items[i] = v
+		}
+		return "[" + strings.Join(items, ",") + "]", nil
+	case e.Fields != nil:
+		fields := make([]string, len(e.Fields))
+		for i, field := range e.Fields {
+			v, err := field.Value.Eval(eval)
+			if err != nil {
+				return "", err
+			}
+			key, _ := json.Marshal(field.Key)
+			fields[i] = string(key) + ":" + v
+		}
+		return "{" + strings.Join(fields, ",") + "}", nil
+	default:
+		return e.JSON, nil
+	}
+}
+
+// NestedCalls returns the outermost nested calls of the expression
+func (e *Expr) NestedCalls() []*Call {
+	switch {
+	case e.Call != nil:
+		return []*Call{e.Call}
+	case e.Items != nil:
+		var calls []*Call
+		for _, item := range e.Items {
+			calls = append(calls, item.NestedCalls()...)
+		}
+		return calls
+	default:
+		var calls []*Call
+		for _, field := range e.Fields {
+			calls = append(calls, field.Value.NestedCalls()...)
+		}
+		return calls
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"sync"
+	"time"
 )
 
 type Callable func(ignoreParams ...any) (resultInterfaces []any)
@@ -16,6 +18,11 @@ type Function struct {
 	def       *Definition
 	ignoreIdx []int
 	fnInfo    *FuncInfo
+	infoErr   error         // the error of finding fnInfo, which is not retried since the source doesn't change
+	infoMu    sync.Mutex    // guards fnInfo which is generated lazily by concurrent calls
+	structIdx int           // index of the single struct parameter, -1 if not in this form
+	timeout   time.Duration // default timeout of InvokeContext, 0 means no timeout
+	schema    *Schema       // parameters schema to validate arguments, nil if not available
 }
 
 // Definition provides the calling information of a function
@@ -46,6 +53,21 @@ func (f *Function) GetFn() any {
 	return f.fn
 }
 
+// SetTimeout sets the default timeout of InvokeContext
+func (f *Function) SetTimeout(timeout time.Duration) {
+	f.timeout = timeout
+}
+
+func (f *Function) GetTimeout() time.Duration {
+	return f.timeout
+}
+
+// IsStructParam reports whether the function takes its arguments by a single struct parameter,
+// in this case the whole argument object is decoded into the struct
+func (f *Function) IsStructParam() bool {
+	return f.structIdx >= 0
+}
+
 // CreateFunction creates a Function from a function
 func CreateFunction(fn any, def Definition, ignoreIdx ...int) (*Function, error) {
 	fv := reflect.ValueOf(fn)
@@ -67,20 +89,45 @@ func CreateFunction(fn any, def Definition, ignoreIdx ...int) (*Function, error)
 	}
 	sort.Ints(newIgnoreIdx)
 
-	// check if the number of parameters in the def is the same as the function
+	// a def without parameters is completed from the function signature
 	fcN := ftN - len(igMap)
+	var fnInfo *FuncInfo
+	var infoErr error
 	if def.Parameters == nil && fcN != 0 {
-		return nil, fmt.Errorf("function %s does not match the number of parameters as the def (%d ignored)", def.Name, len(igMap))
+		// generate the parameters schema by reflection, names are taken from source if possible
+		var names []string
+		if fnInfo, infoErr = GetFunctionDetails(fn); infoErr == nil {
+			names = fnInfo.ParamNames
+		}
+		schema, err := ParametersSchema(fn, names, newIgnoreIdx...)
+		if err != nil {
+			return nil, err
+		}
+		def.Parameters = schema
 	}
 	//if def.Parameters != nil && fcN != len(def.Parameters.Properties) {
 	//	return nil, fmt.Errorf("function %s does not match the number of parameters as the def (%d ignored)", def.Name, len(igMap))
 	//}
 
+	// parameters which are not a valid schema are not validated
+	schema, _ := ToSchema(def.Parameters)
+	structIdx := structParamIdx(funcParams(ft), ft.IsVariadic(), igMap)
+	if structIdx >= 0 {
+		if name, ok := wrappedStructParam(schema, ft.In(structIdx)); ok {
+			return nil, fmt.Errorf("definition of function %s wraps the struct parameter under %s, "+
+				"but the struct is decoded from the whole argument object, the definition should be regenerated", def.Name, name)
+		}
+	}
+
 	function := Function{
 		fn:        fn,
 		funcValue: fv,
 		def:       &def,
 		ignoreIdx: newIgnoreIdx,
+		fnInfo:    fnInfo,
+		infoErr:   infoErr,
+		structIdx: structIdx,
+		schema:    schema,
 	}
 	return &function, nil
 }
@@ -99,6 +146,14 @@ func (f *Function) GetCallable(p *Params) (Callable, error) {
 	if p.Len() != fcN {
 		return nil, fmt.Errorf("parameter count mismatch for function %s", f.GetName())
 	}
+	if p.HasNested() {
+		return nil, fmt.Errorf("nested calls in the parameters of function %s are not evaluated", f.GetName())
+	}
+	if raw {
+		if err = f.validate(p); err != nil {
+			return nil, err
+		}
+	}
 
 	params := make([]reflect.Value, ftN)
 	// i: idx of all params
@@ -117,7 +172,11 @@ func (f *Function) GetCallable(p *Params) (Callable, error) {
 			} else {
 				paramType = ft.In(i)
 			}
-			paramValueI := reflect.New(paramType).Interface()
+			paramValueP := reflect.New(paramType)
+			if i == f.structIdx {
+				initStructParam(paramValueP.Elem())
+			}
+			paramValueI := paramValueP.Interface()
 			err = json.Unmarshal([]byte(rawParam), paramValueI)
 			if err != nil {
 				return nil, fmt.Errorf("invalid parameter: <%s> for function <%s>", rawParam, f.GetName())
@@ -144,6 +203,11 @@ func (f *Function) GetCallable(p *Params) (Callable, error) {
 			panic(fmt.Errorf("function %s ignoreIdx and ignoreParams length mismatch, required %d ignoreParams, %d provided", f.GetName(), len(ignoreIdx), len(ignoreParams)))
 		}
 		for i, idx := range ignoreIdx {
+			if ignoreParams[i] == nil {
+				// nil interface or pointer
+				params[idx] = reflect.Zero(ft.In(idx))
+				continue
+			}
 			params[idx] = reflect.ValueOf(ignoreParams[i])
 		}
 
@@ -157,6 +221,44 @@ func (f *Function) GetCallable(p *Params) (Callable, error) {
 	}, nil
 }
 
+// validate validates raw params against the parameters schema, the errors are returned as ValidationErr
+func (f *Function) validate(p *Params) error {
+	if f.schema == nil {
+		return nil
+	}
+	var errs []ParamErr
+	if f.IsStructParam() {
+		errs = append(f.unknownFields(p.GetRaw(0)), f.schema.ValidateJSON(p.GetRaw(0))...)
+	} else if len(f.schema.Properties) > 0 {
+		ignoreIdxMap := make(map[int]bool)
+		for _, idx := range f.ignoreIdx {
+			ignoreIdxMap[idx] = true
+		}
+		for i, j, names := 0, 0, f.ParamNames(); i < len(names); i++ {
+			if ignoreIdxMap[i] {
+				continue
+			}
+			if ps, ok := f.schema.Properties[names[i]]; ok {
+				errs = append(errs, ps.validateJSON(names[i], p.GetRaw(j))...)
+			}
+			j++
+		}
+	}
+	if len(errs) > 0 {
+		return ValidationErr{Name: f.GetName(), Errors: errs}
+	}
+	return nil
+}
+
+// initStructParam allocates the struct parameter v and fills its default values
+func initStructParam(v reflect.Value) {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	applyDefaults(v)
+}
+
 func (f *Function) Call(params *Params, ignoreParams ...any) (resultInterfaces []any, err error) {
 	callable, err := f.GetCallable(params)
 	if err != nil {
@@ -165,13 +267,21 @@ func (f *Function) Call(params *Params, ignoreParams ...any) (resultInterfaces [
 	return callable(ignoreParams...), nil
 }
 
+// SetFuncInfo sets the FuncInfo of functions whose details can't be found from the func value like methods
+func (f *Function) SetFuncInfo(info *FuncInfo) {
+	f.infoMu.Lock()
+	defer f.infoMu.Unlock()
+	f.fnInfo, f.infoErr = info, nil
+}
+
 func (f *Function) GetOrGenFuncInfo() (*FuncInfo, error) {
-	if f.fnInfo == nil {
-		fnInfo, err := GetFunctionDetails(f.fn)
-		if err != nil {
-			return nil, err
-		}
-		f.fnInfo = fnInfo
+	f.infoMu.Lock()
+	defer f.infoMu.Unlock()
+	if f.fnInfo == nil && f.infoErr == nil {
+		f.fnInfo, f.infoErr = GetFunctionDetails(f.fn)
+	}
+	if f.infoErr != nil {
+		return nil, f.infoErr
 	}
 	return f.fnInfo, nil
 }
@@ -184,6 +294,12 @@ type Call struct {
 type Params struct {
 	Params    []any
 	RawParams []string // will be used when Params is nil, each RawParam is a json string
+	Exprs     []*Expr  // arguments with nested calls instead of RawParams, which should be evaluated by Eval first
+}
+
+// NewStructParams creates Params of a struct parameter function from the json argument object
+func NewStructParams(args string) *Params {
+	return &Params{RawParams: []string{args}}
 }
 
 func (p *Params) IsRaw() bool {
@@ -194,9 +310,42 @@ func (p *Params) Len() int {
 	if p.Params != nil {
 		return len(p.Params)
 	}
+	if p.Exprs != nil {
+		return len(p.Exprs)
+	}
 	return len(p.RawParams)
 }
 
+// HasNested reports whether the arguments contain nested calls
+func (p *Params) HasNested() bool {
+	return p.Exprs != nil
+}
+
+// NestedCalls returns the outermost nested calls of the arguments
+func (p *Params) NestedCalls() []*Call {
+	var calls []*Call
+	for _, e := range p.Exprs {
+		calls = append(calls, e.NestedCalls()...)
+	}
+	return calls
+}
+
+// Eval evaluates the arguments with nested calls bottom-up to RawParams, see Expr.Eval
+func (p *Params) Eval(eval func(call *Call) (string, error)) (*Params, error) {
+	if !p.HasNested() {
+		return p, nil
+	}
+	res := &Params{RawParams: make([]string, len(p.Exprs))}
+	for i, e := range p.Exprs {
+		v, err := e.Eval(eval)
+		if err != nil {
+			return nil, err
+		}
+		res.RawParams[i] = v
+	}
+	return res, nil
+}
+
 func (p *Params) GetRaw(i int) string {
 	return p.RawParams[i]
 }
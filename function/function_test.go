@@ -156,3 +156,25 @@ func TestCreateFunction(t *testing.T) {
 		})
 	}
 }
+
+func TestGetOrGenFuncInfoCached(t *testing.T) {
+	// the method value has no source
+	f, err := CreateFunction(MyStruct{}.SayHello, Definition{Name: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.GetOrGenFuncInfo(); err == nil {
+		t.Fatal("GetOrGenFuncInfo() should fail without source")
+	}
+	if f.infoErr == nil {
+		t.Error("the failed lookup is not cached")
+	}
+	// the names fall back to arg<idx>
+	if names := f.ParamNames(); len(names) != 1 || names[0] != "arg0" {
+		t.Errorf("ParamNames() = %v", names)
+	}
+	f.SetFuncInfo(NewFuncInfo("hello", "", "", []string{"name"}, false))
+	if names := f.ParamNames(); names[0] != "name" {
+		t.Errorf("ParamNames() = %v after SetFuncInfo", names)
+	}
+}
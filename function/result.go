@@ -0,0 +1,210 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// FuncCallErr is returned when the called function fails, e.g. its trailing error result is not nil
+type FuncCallErr struct {
+	Msg  string
+	Name string // the function name
+	Err  error  // the error returned by the function
+}
+
+func (e FuncCallErr) Error() string {
+	return e.Msg
+}
+
+func (e FuncCallErr) Unwrap() error {
+	return e.Err
+}
+
+// PanicErr is returned when the function or its Callable panics
+type PanicErr struct {
+	Name  string // the function name, empty when recovered by Callable.SafeCall
+	Value any    // the recovered value
+	Stack []byte // the stack of the panicking goroutine
+}
+
+func (e PanicErr) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("function panicked: %v", e.Value)
+	}
+	return fmt.Sprintf("function %s panicked: %v", e.Name, e.Value)
+}
+
+// Unwrap returns the recovered value if it's an error
+func (e PanicErr) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// TimeoutErr is returned when the deadline of a call is exceeded before the function returns
+type TimeoutErr struct {
+	Name string // the function name
+	Err  error  // the error of the context, context.DeadlineExceeded
+}
+
+func (e TimeoutErr) Error() string {
+	return fmt.Sprintf("function %s did not return in time: %v", e.Name, e.Err)
+}
+
+func (e TimeoutErr) Unwrap() error {
+	return e.Err
+}
+
+// CanceledErr is returned when the context of a call is cancelled before the function returns
+type CanceledErr struct {
+	Name string // the function name
+	Err  error  // the error of the context, context.Canceled
+}
+
+func (e CanceledErr) Error() string {
+	return fmt.Sprintf("function %s was cancelled: %v", e.Name, e.Err)
+}
+
+func (e CanceledErr) Unwrap() error {
+	return e.Err
+}
+
+// contextErr returns the error of the done ctx of a call to the function name
+func contextErr(name string, ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return TimeoutErr{Name: name, Err: ctx.Err()}
+	}
+	return CanceledErr{Name: name, Err: ctx.Err()}
+}
+
+// SafeCall calls c and recovers its panic as PanicErr, so a misbehaving function can't crash the program
+func (c Callable) SafeCall(ignoreParams ...any) (results []any, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = PanicErr{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return c(ignoreParams...), nil
+}
+
+// Result is the results of a function call except the trailing error
+type Result struct {
+	Values []any
+}
+
+// Len returns the number of values
+func (r *Result) Len() int {
+	return len(r.Values)
+}
+
+// Value returns the first value which is the only one of most functions, nil if there is no value
+func (r *Result) Value() any {
+	if len(r.Values) == 0 {
+		return nil
+	}
+	return r.Values[0]
+}
+
+// Get returns the i-th value
+func (r *Result) Get(i int) any {
+	return r.Values[i]
+}
+
+// JSON encodes the result, a single value is encoded as it is,
+// multiple values are encoded as an array and no value is encoded as null
+func (r *Result) JSON() ([]byte, error) {
+	switch len(r.Values) {
+	case 0:
+		return []byte("null"), nil
+	case 1:
+		return json.Marshal(r.Values[0])
+	default:
+		return json.Marshal(r.Values)
+	}
+}
+
+func (r *Result) String() string {
+	b, err := r.JSON()
+	if err != nil {
+		return fmt.Sprint(r.Values...)
+	}
+	return string(b)
+}
+
+// NewResult creates the Result from the values returned by the Callable of f,
+// a non-nil trailing error is returned as FuncCallErr along with the other values
+func (f *Function) NewResult(values []any) (*Result, error) {
+	ft := f.funcValue.Type()
+	n := ft.NumOut()
+	if n == 0 || ft.Out(n-1) != errorType || len(values) != n {
+		return &Result{Values: values}, nil
+	}
+	res := &Result{Values: values[:n-1]}
+	if err, _ := values[n-1].(error); err != nil {
+		return res, FuncCallErr{
+			Msg:  fmt.Sprintf("function %s failed: %v", f.GetName(), err),
+			Name: f.GetName(),
+			Err:  err,
+		}
+	}
+	return res, nil
+}
+
+// Invoke calls the function with params and returns its Result, see NewResult.
+// Panics are recovered as PanicErr
+func (f *Function) Invoke(params *Params, ignoreParams ...any) (*Result, error) {
+	callable, err := f.GetCallable(params)
+	if err != nil {
+		return nil, err
+	}
+	return f.invoke(callable, ignoreParams...)
+}
+
+// InvokeContext calls the function like Invoke but returns TimeoutErr once the deadline of ctx
+// or the default timeout of the function is exceeded, and CanceledErr once ctx is cancelled.
+// The function itself is not interrupted, it should take ctx by ignoreParams to be cancelled.
+func (f *Function) InvokeContext(ctx context.Context, params *Params, ignoreParams ...any) (*Result, error) {
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	if ctx.Err() != nil {
+		return nil, contextErr(f.GetName(), ctx)
+	}
+	callable, err := f.GetCallable(params)
+	if err != nil {
+		return nil, err
+	}
+	type output struct {
+		res *Result
+		err error
+	}
+	// buffered so the goroutine can finish after timeout
+	done := make(chan output, 1)
+	go func() {
+		res, err := f.invoke(callable, ignoreParams...)
+		done <- output{res, err}
+	}()
+	select {
+	case out := <-done:
+		return out.res, out.err
+	case <-ctx.Done():
+		return nil, contextErr(f.GetName(), ctx)
+	}
+}
+
+func (f *Function) invoke(callable Callable, ignoreParams ...any) (*Result, error) {
+	values, err := callable.SafeCall(ignoreParams...)
+	if err != nil {
+		panicErr := err.(PanicErr)
+		panicErr.Name = f.GetName()
+		return nil, panicErr
+	}
+	return f.NewResult(values)
+}
@@ -0,0 +1,138 @@
+package function
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestInvoke(t *testing.T) {
+	atoi, err := CreateFunction(strconv.Atoi, Definition{Name: "atoi", Parameters: map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := atoi.Invoke(&Params{RawParams: []string{`"42"`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Len() != 1 || res.Value() != 42 || res.String() != "42" {
+		t.Errorf("Invoke() = %v", res.Values)
+	}
+
+	_, err = atoi.Invoke(&Params{RawParams: []string{`"x"`}})
+	var callErr FuncCallErr
+	if !errors.As(err, &callErr) || callErr.Name != "atoi" {
+		t.Fatalf("Invoke() error = %v, want FuncCallErr", err)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Errorf("Invoke() error = %v, want to unwrap *strconv.NumError", err)
+	}
+
+	pair, err := CreateFunction(func(a int) (int, string) { return a, strconv.Itoa(a) }, Definition{Name: "pair", Parameters: map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = pair.Invoke(&Params{RawParams: []string{"7"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := res.JSON(); string(b) != `[7,"7"]` {
+		t.Errorf("JSON() = %s", b)
+	}
+
+	none, err := CreateFunction(func() error { return nil }, Definition{Name: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = none.Invoke(&Params{})
+	if err != nil || res.Len() != 0 || res.Value() != nil {
+		t.Errorf("Invoke() = %v, %v", res, err)
+	}
+	if b, _ := res.JSON(); string(b) != "null" {
+		t.Errorf("JSON() = %s", b)
+	}
+}
+
+func TestInvokePanic(t *testing.T) {
+	boom, err := CreateFunction(func(a int) int { return 10 / a }, Definition{Name: "boom", Parameters: map[string]any{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = boom.Invoke(&Params{RawParams: []string{"0"}})
+	var panicErr PanicErr
+	if !errors.As(err, &panicErr) || panicErr.Name != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("Invoke() error = %v, want PanicErr", err)
+	}
+	var runtimeErr interface{ RuntimeError() }
+	if !errors.As(err, &runtimeErr) {
+		t.Errorf("Invoke() error = %v, want to unwrap runtime error", err)
+	}
+
+	// ignoreParams mismatch panics in the Callable
+	ignored, err := CreateFunction(func(s string, a int) int { return a }, Definition{Name: "ignored", Parameters: map[string]any{}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callable, err := ignored.GetCallable(&Params{RawParams: []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = callable.SafeCall(); !errors.As(err, &panicErr) {
+		t.Errorf("SafeCall() error = %v, want PanicErr", err)
+	}
+	if res, err := callable.SafeCall("x"); err != nil || res[0] != 1 {
+		t.Errorf("SafeCall() = %v, %v", res, err)
+	}
+}
+
+func TestInvokeContext(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow, err := CreateFunction(func(ctx context.Context, d int) int {
+		select {
+		case <-time.After(time.Duration(d) * time.Millisecond):
+		case <-ctx.Done():
+			close(cancelled)
+		}
+		return d
+	}, Definition{Name: "slow", Parameters: map[string]any{}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := slow.InvokeContext(context.Background(), &Params{RawParams: []string{"1"}}, context.Background())
+	if err != nil || res.Value() != 1 {
+		t.Fatalf("InvokeContext() = %v, %v", res, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = slow.InvokeContext(ctx, &Params{RawParams: []string{"10000"}}, ctx)
+	var timeoutErr TimeoutErr
+	if !errors.As(err, &timeoutErr) || timeoutErr.Name != "slow" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("InvokeContext() error = %v, want TimeoutErr", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("the injected context is not cancelled")
+	}
+
+	// default timeout of the function
+	slow.SetTimeout(10 * time.Millisecond)
+	_, err = slow.InvokeContext(context.Background(), &Params{RawParams: []string{"50"}}, context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("InvokeContext() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// cancellation is told apart from the deadline
+	slow.SetTimeout(0)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = slow.InvokeContext(ctx, &Params{RawParams: []string{"50"}}, ctx)
+	var canceledErr CanceledErr
+	if !errors.As(err, &canceledErr) || !errors.Is(err, context.Canceled) || errors.As(err, &timeoutErr) {
+		t.Errorf("InvokeContext() error = %v, want CanceledErr", err)
+	}
+}
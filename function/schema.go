@@ -0,0 +1,376 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a subset of JSON Schema used to describe function parameters
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"` // false or *Schema
+}
+
+func (s *Schema) String() string {
+	jsonStr, _ := json.Marshal(s)
+	return string(jsonStr)
+}
+
+// RemoveProperty removes the property and its requirement
+func (s *Schema) RemoveProperty(name string) {
+	delete(s.Properties, name)
+	for i, r := range s.Required {
+		if r == name {
+			s.Required = append(s.Required[:i:i], s.Required[i+1:]...)
+			break
+		}
+	}
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// ContextIdx returns the indexes of context.Context parameters of a golang func,
+// these parameters can never be provided by the LLM
+func ContextIdx(fn any) []int {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil
+	}
+	var idx []int
+	for i := 0; i < ft.NumIn(); i++ {
+		if ft.In(i) == contextType {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// SchemaOf generates the JSON Schema of a golang type by reflection
+func SchemaOf(t reflect.Type) *Schema {
+	return schemaOf(reflectType{t}, make(map[any]bool))
+}
+
+// schemaType is a golang type seen by reflection or by go/types,
+// so that the schemas generated at runtime and ahead of time from source are the same
+type schemaType interface {
+	kind() reflect.Kind    // the kind of the underlying type, reflect.Invalid if it's unknown
+	elem() schemaType      // the element type of pointers, slices, arrays and maps
+	key() schemaType       // the key type of maps
+	fields() []schemaField // the fields of structs
+	isTime() bool
+	id() any // the identity of the type to cut off recursive types
+}
+
+type schemaField struct {
+	name      string
+	exported  bool
+	anonymous bool
+	tag       reflect.StructTag
+	typ       schemaType
+}
+
+type reflectType struct {
+	t reflect.Type
+}
+
+func (t reflectType) kind() reflect.Kind { return t.t.Kind() }
+func (t reflectType) elem() schemaType   { return reflectType{t.t.Elem()} }
+func (t reflectType) key() schemaType    { return reflectType{t.t.Key()} }
+func (t reflectType) isTime() bool       { return t.t == timeType }
+func (t reflectType) id() any            { return t.t }
+
+func (t reflectType) fields() []schemaField {
+	fields := make([]schemaField, t.t.NumField())
+	for i := range fields {
+		f := t.t.Field(i)
+		fields[i] = schemaField{name: f.Name, exported: f.PkgPath == "", anonymous: f.Anonymous, tag: f.Tag, typ: reflectType{f.Type}}
+	}
+	return fields
+}
+
+func schemaOf(t schemaType, visiting map[any]bool) *Schema {
+	for t.kind() == reflect.Ptr {
+		t = t.elem()
+	}
+	if t.isTime() {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.elem().kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as a base64 string
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOf(t.elem(), visiting)}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.elem(), visiting)}
+	case reflect.Map:
+		if t.key().kind() != reflect.String {
+			return &Schema{Type: "object"}
+		}
+		return &Schema{Type: "object", AdditionalProperties: schemaOf(t.elem(), visiting)}
+	case reflect.Struct:
+		// recursive types are cut off at the second level
+		if visiting[t.id()] {
+			return &Schema{Type: "object"}
+		}
+		visiting[t.id()] = true
+		defer delete(visiting, t.id())
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+		addStructFields(s, t, visiting)
+		return s
+	default:
+		// interface, func, chan...: anything is accepted
+		return &Schema{}
+	}
+}
+
+// addStructFields adds the exported fields of struct t to s following encoding/json naming rules
+func addStructFields(s *Schema, t schemaType, visiting map[any]bool) {
+	for _, field := range t.fields() {
+		name, omitempty, skip := parseJSONTag(field.tag)
+		if skip {
+			continue
+		}
+		ft := field.typ
+		for ft.kind() == reflect.Ptr {
+			ft = ft.elem()
+		}
+		// embedded structs without a json name are flattened
+		if field.anonymous && name == "" && ft.kind() == reflect.Struct {
+			addStructFields(s, ft, visiting)
+			continue
+		}
+		if !field.exported {
+			continue
+		}
+		if name == "" {
+			name = field.name
+		}
+		fs := schemaOf(field.typ, visiting)
+		applySchemaTags(fs, field.tag, ft.kind())
+		s.Properties[name] = fs
+		required := !omitempty
+		if v, ok := field.tag.Lookup("required"); ok {
+			required, _ = strconv.ParseBool(v)
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// applySchemaTags applies the schema struct tags of a field of kind k:
+//
+//	description:"the city name" enum:"celsius,fahrenheit" default:"celsius" minimum:"0" maximum:"100"
+//	minLength:"1" maxLength:"64" pattern:"^[a-z]+$"
+func applySchemaTags(s *Schema, tag reflect.StructTag, k reflect.Kind) {
+	if v, ok := tag.Lookup("description"); ok {
+		s.Description = v
+	}
+	if v, ok := tag.Lookup("enum"); ok {
+		for _, e := range strings.Split(v, ",") {
+			s.Enum = append(s.Enum, parseTagValue(k, strings.TrimSpace(e)))
+		}
+	}
+	if v, ok := tag.Lookup("default"); ok {
+		s.Default = parseTagValue(k, v)
+	}
+	if v, ok := tag.Lookup("minimum"); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			s.Minimum = &f
+		}
+	}
+	if v, ok := tag.Lookup("maximum"); ok {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			s.Maximum = &f
+		}
+	}
+	if v, ok := tag.Lookup("minLength"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			s.MinLength = &n
+		}
+	}
+	if v, ok := tag.Lookup("maxLength"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			s.MaxLength = &n
+		}
+	}
+	if v, ok := tag.Lookup("pattern"); ok {
+		s.Pattern = v
+	}
+}
+
+// parseTagValue converts a tag value of a field of kind k to json value, the raw string is kept if it's not valid json
+func parseTagValue(k reflect.Kind, v string) any {
+	if k == reflect.String {
+		return v
+	}
+	var res any
+	if err := json.Unmarshal([]byte(v), &res); err != nil {
+		return v
+	}
+	return res
+}
+
+// applyDefaults sets the fields of struct v to the value of their default tag,
+// it should be called before the json is decoded into v
+func applyDefaults(v reflect.Value) {
+	if v.Kind() != reflect.Struct || v.Type() == timeType {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if !fv.CanSet() {
+			continue
+		}
+		if d, ok := field.Tag.Lookup("default"); ok {
+			if field.Type.Kind() == reflect.String {
+				fv.SetString(d)
+				continue
+			}
+			pv := reflect.New(field.Type)
+			if err := json.Unmarshal([]byte(d), pv.Interface()); err == nil {
+				fv.Set(pv.Elem())
+			}
+			continue
+		}
+		applyDefaults(fv)
+	}
+}
+
+func parseJSONTag(tag reflect.StructTag) (name string, omitempty bool, skip bool) {
+	jsonTag := tag.Get("json")
+	if jsonTag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(jsonTag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty, false
+}
+
+// ParametersSchema generates the parameters object schema of a golang func,
+// names are the parameter names in order, missing names are replaced by arg<idx>,
+// ignored parameters are excluded.
+// If the only parameter left is a struct, the schema of the struct is used directly.
+func ParametersSchema(fn any, names []string, ignoreIdx ...int) (*Schema, error) {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil, fmt.Errorf("fn is not a function")
+	}
+	igMap := make(map[int]bool)
+	for _, idx := range ignoreIdx {
+		igMap[idx] = true
+	}
+	return parametersSchema(funcParams(ft), ft.IsVariadic(), names, igMap), nil
+}
+
+// funcParams returns the parameter types of the func type
+func funcParams(ft reflect.Type) []schemaType {
+	params := make([]schemaType, ft.NumIn())
+	for i := range params {
+		params[i] = reflectType{ft.In(i)}
+	}
+	return params
+}
+
+// parametersSchema generates the parameters schema of a func whose parameter types are params
+func parametersSchema(params []schemaType, variadic bool, names []string, igMap map[int]bool) *Schema {
+	if idx := structParamIdx(params, variadic, igMap); idx >= 0 {
+		return schemaOf(params[idx], make(map[any]bool))
+	}
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i, p := range params {
+		if igMap[i] {
+			continue
+		}
+		name := ParamName(names, i)
+		// the variadic parameter is passed as a slice, which both reflect and go/types report
+		s.Properties[name] = schemaOf(p, make(map[any]bool))
+		s.Required = append(s.Required, name)
+	}
+	return s
+}
+
+// structParamIdx returns the index of the single struct parameter, -1 if the func is not in this form
+func structParamIdx(params []schemaType, variadic bool, igMap map[int]bool) int {
+	idx := -1
+	for i := range params {
+		if igMap[i] {
+			continue
+		}
+		if idx >= 0 {
+			return -1
+		}
+		idx = i
+	}
+	if idx < 0 || (variadic && idx == len(params)-1) {
+		return -1
+	}
+	t := params[idx]
+	if t.kind() == reflect.Ptr {
+		t = t.elem()
+	}
+	if t.kind() != reflect.Struct || t.isTime() {
+		return -1
+	}
+	return idx
+}
+
+// wrappedStructParam reports whether the parameters schema wraps the struct t under a single property like {"p":{...}},
+// which is the form of definitions generated before struct parameters took the whole argument object
+func wrappedStructParam(schema *Schema, t reflect.Type) (string, bool) {
+	if schema == nil || len(schema.Properties) != 1 {
+		return "", false
+	}
+	fields := SchemaOf(t).Properties
+	for name, prop := range schema.Properties {
+		if _, ok := fields[name]; !ok && prop != nil && (prop.Type == "object" || len(prop.Properties) > 0) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ParamName returns the name of the i-th parameter, arg<i> if names doesn't have it
+func ParamName(names []string, i int) string {
+	if i < len(names) && names[i] != "" && names[i] != "_" {
+		return names[i]
+	}
+	return fmt.Sprintf("arg%d", i)
+}
@@ -0,0 +1,205 @@
+package function
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Address struct {
+	City   string `json:"city"`
+	Street string `json:"street,omitempty"`
+}
+
+type Person struct {
+	Name     string             `json:"name"`
+	Age      int                `json:"age"`
+	Tags     []string           `json:"tags,omitempty"`
+	Address  *Address           `json:"address"`
+	Extra    map[string]float64 `json:"extra,omitempty"`
+	Birthday time.Time          `json:"birthday"`
+	Secret   string             `json:"-"`
+	Friends  []*Person          `json:"friends,omitempty"`
+	internal int
+}
+
+func TestSchemaOf(t *testing.T) {
+	tests := []struct {
+		name string
+		t    reflect.Type
+		want string
+	}{
+		{"int", reflect.TypeOf(0), `{"type":"integer"}`},
+		{"uint8", reflect.TypeOf(uint8(0)), `{"type":"integer"}`},
+		{"float", reflect.TypeOf(0.1), `{"type":"number"}`},
+		{"string", reflect.TypeOf(""), `{"type":"string"}`},
+		{"bool", reflect.TypeOf(true), `{"type":"boolean"}`},
+		{"bytes", reflect.TypeOf([]byte{}), `{"type":"string","format":"byte"}`},
+		{"slice", reflect.TypeOf([][]int{}), `{"type":"array","items":{"type":"array","items":{"type":"integer"}}}`},
+		{"map", reflect.TypeOf(map[string]bool{}), `{"type":"object","additionalProperties":{"type":"boolean"}}`},
+		{"pointer", reflect.TypeOf(new(string)), `{"type":"string"}`},
+		{"any", reflect.TypeOf((*any)(nil)).Elem(), `{}`},
+		{
+			"struct",
+			reflect.TypeOf(Address{}),
+			`{"type":"object","properties":{"city":{"type":"string"},"street":{"type":"string"}},"required":["city"],"additionalProperties":false}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SchemaOf(tt.t).String(); got != tt.want {
+				t.Errorf("SchemaOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaOfNestedStruct(t *testing.T) {
+	s := SchemaOf(reflect.TypeOf(Person{}))
+	if s.Type != "object" {
+		t.Fatalf("SchemaOf() type = %s, want object", s.Type)
+	}
+	wantProps := []string{"name", "age", "tags", "address", "extra", "birthday", "friends"}
+	if len(s.Properties) != len(wantProps) {
+		t.Fatalf("SchemaOf() properties = %v, want %v", s, wantProps)
+	}
+	for _, p := range wantProps {
+		if _, ok := s.Properties[p]; !ok {
+			t.Errorf("SchemaOf() missing property %s", p)
+		}
+	}
+	if got := s.Properties["address"].Properties["city"].Type; got != "string" {
+		t.Errorf("SchemaOf() address.city type = %s, want string", got)
+	}
+	if got := s.Properties["birthday"].Format; got != "date-time" {
+		t.Errorf("SchemaOf() birthday format = %s, want date-time", got)
+	}
+	// recursive type is cut off
+	if got := s.Properties["friends"].Items.String(); got != `{"type":"object"}` {
+		t.Errorf("SchemaOf() friends items = %s", got)
+	}
+	if want := []string{"name", "age", "address", "birthday"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("SchemaOf() required = %v, want %v", s.Required, want)
+	}
+}
+
+func TestParametersSchema(t *testing.T) {
+	fn := func(ctx context.Context, a, b int, opts ...string) int { return a + b }
+	s, err := ParametersSchema(fn, []string{"ctx", "a", "b", "opts"}, ContextIdx(fn)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{"a":{"type":"integer"},"b":{"type":"integer"},"opts":{"type":"array","items":{"type":"string"}}},"required":["a","b","opts"]}`
+	if got := s.String(); got != want {
+		t.Errorf("ParametersSchema() = %s, want %s", got, want)
+	}
+
+	// names are not available
+	s, err = ParametersSchema(func(string, bool) {}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(s.Required)
+	if string(b) != `["arg0","arg1"]` {
+		t.Errorf("ParametersSchema() required = %s", b)
+	}
+
+	if _, err = ParametersSchema(1, nil); err == nil {
+		t.Errorf("ParametersSchema() expected error for non func")
+	}
+}
+
+type SearchRequest struct {
+	Query string   `json:"query" description:"the search keywords"`
+	Sort  string   `json:"sort" enum:"relevance,date" default:"relevance"`
+	Limit int      `json:"limit" minimum:"1" maximum:"50" default:"10" required:"false"`
+	Langs []string `json:"langs,omitempty" required:"true"`
+}
+
+func TestSchemaOfStructTags(t *testing.T) {
+	s := SchemaOf(reflect.TypeOf(SearchRequest{}))
+	want := `{"type":"object","properties":{` +
+		`"langs":{"type":"array","items":{"type":"string"}},` +
+		`"limit":{"type":"integer","default":10,"minimum":1,"maximum":50},` +
+		`"query":{"type":"string","description":"the search keywords"},` +
+		`"sort":{"type":"string","enum":["relevance","date"],"default":"relevance"}},` +
+		`"required":["query","sort","langs"],"additionalProperties":false}`
+	if got := s.String(); got != want {
+		t.Errorf("SchemaOf() = %s, want %s", got, want)
+	}
+}
+
+func TestStructParamFunction(t *testing.T) {
+	fn := func(ctx context.Context, req *SearchRequest) string {
+		return fmt.Sprintf("%s|%s|%d|%v", req.Query, req.Sort, req.Limit, req.Langs)
+	}
+	f, err := CreateFunction(fn, Definition{Name: "search"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsStructParam() {
+		t.Fatalf("IsStructParam() = false, want true")
+	}
+	if got := f.GetDef().Parameters.(*Schema).Properties["query"].Description; got != "the search keywords" {
+		t.Errorf("Parameters query description = %s", got)
+	}
+	res, err := f.Call(NewStructParams(`{"query":"golang","langs":["en"]}`), context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "golang|relevance|10|[en]"; res[0] != want {
+		t.Errorf("Call() = %v, want %v", res[0], want)
+	}
+}
+
+func TestStructParamLegacyDef(t *testing.T) {
+	fn := func(req SearchRequest) string { return req.Query }
+	// definitions generated before the struct took the whole argument object
+	legacy := Definition{Name: "search", Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"req": map[string]any{"type": "object", "properties": map[string]any{"query": map[string]any{"type": "string"}}},
+		},
+	}}
+	if _, err := CreateFunction(fn, legacy); err == nil || !strings.Contains(err.Error(), "wraps the struct parameter under req") {
+		t.Errorf("CreateFunction() error = %v", err)
+	}
+
+	f, err := CreateFunction(fn, Definition{Name: "search"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.GetCallable(NewStructParams(`{"req":{"query":"golang"}}`))
+	var validationErr ValidationErr
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("GetCallable() error = %v", err)
+	}
+	// the extra field is reported once
+	var unknown []string
+	for _, e := range validationErr.Errors {
+		if e.Msg == "unknown property" {
+			unknown = append(unknown, e.Path)
+		}
+	}
+	if len(unknown) != 1 || unknown[0] != "req" {
+		t.Errorf("GetCallable() error = %v", err)
+	}
+
+	// fields unknown to a schema allowing additional properties would be dropped by decoding
+	f, err = CreateFunction(fn, Definition{Name: "search", Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"query": map[string]any{"type": "string"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.GetCallable(NewStructParams(`{"query":"golang","zip":"10001"}`))
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 || validationErr.Errors[0].Path != "zip" {
+		t.Errorf("GetCallable() error = %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package function
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// funcInfoTable stores the precomputed FuncInfo by runtime function name,
+// it's filled by the code generated by cmd/nlcall-gen so that source files are not needed at runtime
+var funcInfoTable = struct {
+	sync.RWMutex
+	m map[string]*FuncInfo
+}{m: make(map[string]*FuncInfo)}
+
+// RegisterFuncInfo registers the precomputed FuncInfo of fn
+func RegisterFuncInfo(fn any, info *FuncInfo) {
+	funcInfoTable.Lock()
+	defer funcInfoTable.Unlock()
+	funcInfoTable.m[runtimeName(fn)] = info
+}
+
+// lookupFuncInfo looks up the precomputed FuncInfo of fn
+func lookupFuncInfo(fn any) (*FuncInfo, bool) {
+	funcInfoTable.RLock()
+	defer funcInfoTable.RUnlock()
+	info, ok := funcInfoTable.m[runtimeName(fn)]
+	return info, ok
+}
+
+// runtimeName returns the runtime name of fn, method values share the name of their method expressions
+func runtimeName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return ""
+	}
+	return strings.TrimSuffix(f.Name(), "-fm")
+}
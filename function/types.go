@@ -0,0 +1,126 @@
+package function
+
+import (
+	"go/types"
+	"reflect"
+)
+
+// SignatureSchema generates the parameters schema of a function type checked by go/types,
+// which is the same as ParametersSchema generates by reflection. context.Context parameters are ignored
+func SignatureSchema(sig *types.Signature, names []string) *Schema {
+	params := make([]schemaType, sig.Params().Len())
+	igMap := make(map[int]bool)
+	for i := range params {
+		t := sig.Params().At(i).Type()
+		if isNamed(t, "context", "Context") {
+			igMap[i] = true
+		}
+		params[i] = typesType{t}
+	}
+	return parametersSchema(params, sig.Variadic(), names, igMap)
+}
+
+var basicKinds = map[types.BasicKind]reflect.Kind{
+	types.Bool:    reflect.Bool,
+	types.Int:     reflect.Int,
+	types.Int8:    reflect.Int8,
+	types.Int16:   reflect.Int16,
+	types.Int32:   reflect.Int32,
+	types.Int64:   reflect.Int64,
+	types.Uint:    reflect.Uint,
+	types.Uint8:   reflect.Uint8,
+	types.Uint16:  reflect.Uint16,
+	types.Uint32:  reflect.Uint32,
+	types.Uint64:  reflect.Uint64,
+	types.Uintptr: reflect.Uintptr,
+	types.Float32: reflect.Float32,
+	types.Float64: reflect.Float64,
+	types.String:  reflect.String,
+}
+
+// typesType is a schemaType of go/types, types which failed to type check are unknown
+type typesType struct {
+	t types.Type
+}
+
+func (t typesType) kind() reflect.Kind {
+	switch u := t.t.Underlying().(type) {
+	case *types.Basic:
+		return basicKinds[u.Kind()]
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Array:
+		return reflect.Array
+	case *types.Map:
+		return reflect.Map
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Interface:
+		return reflect.Interface
+	case *types.Signature:
+		return reflect.Func
+	case *types.Chan:
+		return reflect.Chan
+	}
+	return reflect.Invalid
+}
+
+func (t typesType) elem() schemaType {
+	switch u := t.t.Underlying().(type) {
+	case *types.Pointer:
+		return typesType{u.Elem()}
+	case *types.Slice:
+		return typesType{u.Elem()}
+	case *types.Array:
+		return typesType{u.Elem()}
+	case *types.Map:
+		return typesType{u.Elem()}
+	}
+	return typesType{types.Typ[types.Invalid]}
+}
+
+func (t typesType) key() schemaType {
+	if m, ok := t.t.Underlying().(*types.Map); ok {
+		return typesType{m.Key()}
+	}
+	return typesType{types.Typ[types.Invalid]}
+}
+
+func (t typesType) fields() []schemaField {
+	st, ok := t.t.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	fields := make([]schemaField, st.NumFields())
+	for i := range fields {
+		f := st.Field(i)
+		fields[i] = schemaField{
+			name:      f.Name(),
+			exported:  f.Exported(),
+			anonymous: f.Embedded(),
+			tag:       reflect.StructTag(st.Tag(i)),
+			typ:       typesType{f.Type()},
+		}
+	}
+	return fields
+}
+
+func (t typesType) isTime() bool {
+	return isNamed(t.t, "time", "Time")
+}
+
+func (t typesType) id() any {
+	return t.t
+}
+
+// isNamed reports whether t is the named type pkgPath.name
+func isNamed(t types.Type, pkgPath, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
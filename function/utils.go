@@ -1,6 +1,8 @@
 package function
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -16,11 +18,14 @@ type FuncInfo struct {
 	Comments   string               `json:"comments"`
 	SourceCode string               `json:"source_code"`
 	Params     map[string]ParamInfo `json:"-"` // ignore by json
+	ParamNames []string             `json:"-"` // parameter names in declaration order, "" for unnamed
+	Variadic   bool                 `json:"-"` // whether the last parameter is variadic
 }
 
 type ParamInfo struct {
-	Name  string
-	Index int
+	Name     string
+	Index    int  // position in the parameter list
+	Variadic bool // whether it's the variadic parameter
 }
 
 func GetFunctionDetails(fn any) (*FuncInfo, error) {
@@ -30,80 +35,260 @@ func GetFunctionDetails(fn any) (*FuncInfo, error) {
 		return nil, fmt.Errorf("fn is not a function")
 	}
 
+	// precomputed by nlcall-gen
+	if info, ok := lookupFuncInfo(fn); ok {
+		return info, nil
+	}
+
 	// 使用 runtime 获取函数的文件和起始行
 	pc := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
 	if pc == nil {
 		return nil, fmt.Errorf("unable to get function info")
 	}
+	if strings.HasSuffix(pc.Name(), "-fm") {
+		// the wrapper of a method value has no source
+		return nil, fmt.Errorf("method value %s has no source, use GetMethodDetails instead", pc.Name())
+	}
 
 	file, startLine := pc.FileLine(pc.Entry())
-	fmt.Printf("Function defined in file: %s at line: %d\n", file, startLine)
 
-	// 打开源码文件
-	srcData, err := os.ReadFile(file)
+	fset, node, src, err := parseSourceFile(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read source file: %w", err)
-	}
-
-	src := string(srcData)
-
-	// 解析源码文件
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, file, src, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse source file: %w", err)
+		return nil, err
 	}
 
 	// 查找对应的函数定义
-	var funcName, funcSource, funcComment string
-	params := make(map[string]ParamInfo)
-
+	var info *FuncInfo
+	var lits []*FuncInfo // closures at startLine
+	// names of function literals assigned to a variable like add := func(a, b int) int {...}
+	litNames := make(map[*ast.FuncLit]string)
 	ast.Inspect(node, func(n ast.Node) bool {
-		if fn, ok := n.(*ast.FuncDecl); ok {
+		if info != nil {
+			return false
+		}
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
 			funcPos := fset.Position(fn.Pos())
 			if funcPos.Line == startLine {
-				// 获取函数名称
-				funcName = fn.Name.Name
-
-				// 提取函数源码
-				start := fset.Position(fn.Pos()).Offset
-				end := fset.Position(fn.End()).Offset
-				funcSource = src[start:end]
-
-				// 获取函数注释
-				if fn.Doc != nil {
-					var comments []string
-					for _, comment := range fn.Doc.List {
-						comments = append(comments, strings.TrimSpace(comment.Text))
+				info = FuncInfoFromDecl(fset, src, fn)
+				return false
+			}
+		case *ast.AssignStmt:
+			if len(fn.Lhs) == len(fn.Rhs) {
+				for i, rhs := range fn.Rhs {
+					lit, ok1 := rhs.(*ast.FuncLit)
+					id, ok2 := fn.Lhs[i].(*ast.Ident)
+					if ok1 && ok2 {
+						litNames[lit] = id.Name
 					}
-					funcComment = strings.Join(comments, "\n")
 				}
-
-				// 获取参数信息
-				if fn.Type.Params != nil {
-					for i, param := range fn.Type.Params.List {
-						for _, name := range param.Names {
-							params[name.Name] = ParamInfo{
-								Name:  name.Name,
-								Index: i,
-							}
-						}
+			}
+		case *ast.ValueSpec:
+			if len(fn.Names) == len(fn.Values) {
+				for i, v := range fn.Values {
+					if lit, ok := v.(*ast.FuncLit); ok {
+						litNames[lit] = fn.Names[i].Name
 					}
 				}
-				return false
+			}
+		case *ast.FuncLit:
+			// closures start at the line of the func keyword, only the ones with the same number of parameters match
+			if fset.Position(fn.Pos()).Line == startLine {
+				lit := funcInfoFromType(litNames[fn], "", src[fset.Position(fn.Pos()).Offset:fset.Position(fn.End()).Offset], fn.Type)
+				if len(lit.ParamNames) == fnType.NumIn() {
+					lits = append(lits, lit)
+				}
 			}
 		}
 		return true
 	})
 
-	if funcSource == "" {
+	if info == nil && len(lits) > 1 {
+		return nil, fmt.Errorf("%d closures at line %d match the function, it can't be told which one it is", len(lits), startLine)
+	}
+	if info == nil && len(lits) == 1 {
+		info = lits[0]
+	}
+	if info == nil {
 		return nil, fmt.Errorf("function not found at line %d", startLine)
 	}
+	return info, nil
+}
+
+// GetMethodDetails gets the FuncInfo of the method of type t (T or *T) by name,
+// the declaration is located by its receiver type and the receiver is not a parameter of the FuncInfo
+func GetMethodDetails(t reflect.Type, name string) (*FuncInfo, error) {
+	base := t
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	// the method is declared on either T or *T, the other one is autogenerated
+	for _, rt := range []reflect.Type{base, reflect.PtrTo(base)} {
+		m, ok := rt.MethodByName(name)
+		if !ok {
+			continue
+		}
+		// precomputed by nlcall-gen
+		if info, ok := lookupFuncInfo(m.Func.Interface()); ok {
+			return info, nil
+		}
+		pc := runtime.FuncForPC(m.Func.Pointer())
+		if pc == nil {
+			continue
+		}
+		file, _ := pc.FileLine(pc.Entry())
+		if file == "<autogenerated>" {
+			continue
+		}
+		fset, node, src, err := parseSourceFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range node.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if ok && fn.Recv != nil && fn.Name.Name == name && recvTypeName(fn) == base.Name() {
+				return FuncInfoFromDecl(fset, src, fn), nil
+			}
+		}
+		return nil, fmt.Errorf("method %s.%s not found in %s", base.Name(), name, file)
+	}
+	return nil, fmt.Errorf("method %s.%s not found", base.Name(), name)
+}
+
+// IsPromotedMethod reports whether the method of type t (T or *T) is promoted from an embedded field,
+// which is only implemented by autogenerated wrappers and has no declaration of t
+func IsPromotedMethod(t reflect.Type, name string) bool {
+	base := t
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	for _, rt := range []reflect.Type{base, reflect.PtrTo(base)} {
+		m, ok := rt.MethodByName(name)
+		if !ok {
+			continue
+		}
+		if pc := runtime.FuncForPC(m.Func.Pointer()); pc != nil {
+			if file, _ := pc.FileLine(pc.Entry()); file != "<autogenerated>" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// recvTypeName returns the receiver type name of a method declaration without pointer and type parameters
+func recvTypeName(fn *ast.FuncDecl) string {
+	if len(fn.Recv.List) == 0 {
+		return ""
+	}
+	expr := fn.Recv.List[0].Type
+	for {
+		switch t := expr.(type) {
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.IndexExpr:
+			expr = t.X
+		case *ast.IndexListExpr:
+			expr = t.X
+		case *ast.Ident:
+			return t.Name
+		default:
+			return ""
+		}
+	}
+}
+
+func parseSourceFile(file string) (*token.FileSet, *ast.File, string, error) {
+	// 打开源码文件
+	srcData, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to read source file: %w", err)
+	}
+	src := string(srcData)
+
+	// 解析源码文件
+	fset := token.NewFileSet()
+	node, err := parser.ParseFile(fset, file, src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to parse source file: %w", err)
+	}
+	return fset, node, src, nil
+}
+
+// FuncInfoFromDecl extracts the FuncInfo of a function declaration, src is the source of the file
+func FuncInfoFromDecl(fset *token.FileSet, src string, fn *ast.FuncDecl) *FuncInfo {
+	// 提取函数源码
+	start := fset.Position(fn.Pos()).Offset
+	end := fset.Position(fn.End()).Offset
+	funcSource := src[start:end]
+
+	// 获取函数注释
+	var funcComment string
+	if fn.Doc != nil {
+		var comments []string
+		for _, comment := range fn.Doc.List {
+			// nlcall directives are not part of the doc
+			if strings.HasPrefix(comment.Text, "//nlcall:") {
+				continue
+			}
+			comments = append(comments, strings.TrimSpace(comment.Text))
+		}
+		// the blank line separating the doc from the directives is not part of the doc
+		for len(comments) > 0 && comments[len(comments)-1] == "//" {
+			comments = comments[:len(comments)-1]
+		}
+		funcComment = strings.Join(comments, "\n")
+	}
 
+	return funcInfoFromType(fn.Name.Name, funcComment, funcSource, fn.Type)
+}
+
+// funcInfoFromType creates a FuncInfo with the parameters of the function type
+func funcInfoFromType(name, comments, source string, ft *ast.FuncType) *FuncInfo {
+	// 获取参数信息
+	var paramNames []string
+	variadic := false
+	if ft.Params != nil {
+		// grouped declarations like (a, b int) take one position per name
+		for _, param := range ft.Params.List {
+			_, variadic = param.Type.(*ast.Ellipsis)
+			if len(param.Names) == 0 {
+				paramNames = append(paramNames, "")
+			}
+			for _, name := range param.Names {
+				paramNames = append(paramNames, name.Name)
+			}
+		}
+	}
+	return NewFuncInfo(name, comments, source, paramNames, variadic)
+}
+
+// NewFuncInfo creates a FuncInfo, paramNames are the parameter names by position
+// and variadic reports whether the last parameter is variadic
+func NewFuncInfo(name, comments, sourceCode string, paramNames []string, variadic bool) *FuncInfo {
+	params := make(map[string]ParamInfo)
+	for i, n := range paramNames {
+		if n == "" || n == "_" {
+			continue
+		}
+		params[n] = ParamInfo{
+			Name:     n,
+			Index:    i,
+			Variadic: variadic && i == len(paramNames)-1,
+		}
+	}
 	return &FuncInfo{
-		Name:       funcName,
-		Comments:   funcComment,
-		SourceCode: funcSource,
+		Name:       name,
+		Comments:   comments,
+		SourceCode: sourceCode,
 		Params:     params,
-	}, nil
+		ParamNames: paramNames,
+		Variadic:   variadic,
+	}
+}
+
+// SourceHash returns the hash of the function source, which changes with the signature or the body
+func SourceHash(info *FuncInfo) string {
+	sum := sha256.Sum256([]byte(info.SourceCode))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,108 @@
+package function
+
+import (
+	"reflect"
+	"testing"
+)
+
+type Calendar struct{}
+
+// CreateEvent creates an event
+func (c *Calendar) CreateEvent(title string, day, hour int) string { return title }
+
+// Events lists the events of a day
+func (c Calendar) Events(day int) []string { return nil }
+
+func TestGetMethodDetails(t *testing.T) {
+	tests := []struct {
+		name   string
+		t      reflect.Type
+		method string
+		params []string
+		src    string
+	}{
+		{"pointer receiver", reflect.TypeOf(&Calendar{}), "CreateEvent", []string{"title", "day", "hour"}, "func (c *Calendar) CreateEvent"},
+		{"value receiver", reflect.TypeOf(Calendar{}), "Events", []string{"day"}, "func (c Calendar) Events"},
+		{"value receiver by pointer", reflect.TypeOf(&Calendar{}), "Events", []string{"day"}, "func (c Calendar) Events"},
+		{"other receiver", reflect.TypeOf(MyStruct{}), "SayHello", []string{"name"}, "func (s MyStruct) SayHello"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := GetMethodDetails(tt.t, tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Name != tt.method || !reflect.DeepEqual(info.ParamNames, tt.params) {
+				t.Errorf("GetMethodDetails() = %s %v", info.Name, info.ParamNames)
+			}
+			if len(info.SourceCode) < len(tt.src) || info.SourceCode[:len(tt.src)] != tt.src {
+				t.Errorf("GetMethodDetails() source = %s", info.SourceCode)
+			}
+		})
+	}
+	if _, err := GetMethodDetails(reflect.TypeOf(Calendar{}), "Missing"); err == nil {
+		t.Errorf("GetMethodDetails() expected error for missing method")
+	}
+	// method values have no source
+	if _, err := GetFunctionDetails(Calendar{}.Events); err == nil {
+		t.Errorf("GetFunctionDetails() expected error for method value")
+	}
+}
+
+// SharedCalendar has the methods of Calendar promoted and declares its own Events
+type SharedCalendar struct {
+	*Calendar
+}
+
+// Events lists the shared events of a day
+func (c SharedCalendar) Events(day int) []string { return nil }
+
+func TestIsPromotedMethod(t *testing.T) {
+	typ := reflect.TypeOf(&SharedCalendar{})
+	if !IsPromotedMethod(typ, "CreateEvent") {
+		t.Error("CreateEvent is promoted")
+	}
+	if IsPromotedMethod(typ, "Events") || IsPromotedMethod(reflect.TypeOf(&Calendar{}), "CreateEvent") {
+		t.Error("declared methods are not promoted")
+	}
+}
+
+func makeGreeter(greeting string) func(name string) string {
+	return func(name string) string { return greeting + " " + name }
+}
+
+func TestGetFunctionDetailsFuncLit(t *testing.T) {
+	mul := func(a, b int) int { return a * b }
+	info, err := GetFunctionDetails(mul)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "mul" || !reflect.DeepEqual(info.ParamNames, []string{"a", "b"}) || info.SourceCode != "func(a, b int) int { return a * b }" {
+		t.Errorf("GetFunctionDetails() = %+v", info)
+	}
+
+	info, err = GetFunctionDetails(makeGreeter("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "" || !reflect.DeepEqual(info.ParamNames, []string{"name"}) {
+		t.Errorf("GetFunctionDetails() = %+v", info)
+	}
+
+	// closures on the same line are told apart by the number of parameters
+	neg, add := func(a int) int { return -a }, func(a, b int) int { return a + b }
+	if info, err = GetFunctionDetails(add); err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "add" || !reflect.DeepEqual(info.ParamNames, []string{"a", "b"}) {
+		t.Errorf("GetFunctionDetails() = %+v", info)
+	}
+	if info, err = GetFunctionDetails(neg); err != nil || info.Name != "neg" {
+		t.Errorf("GetFunctionDetails() = %+v, %v", info, err)
+	}
+	sub, mod := func(a, b int) int { return a - b }, func(a, b int) int { return a % b }
+	if _, err = GetFunctionDetails(sub); err == nil {
+		t.Error("GetFunctionDetails() of ambiguous closures should fail")
+	}
+	_ = mod
+}
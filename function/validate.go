@@ -0,0 +1,250 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParamErr describes why an argument doesn't match its schema
+type ParamErr struct {
+	Path string // the path of the argument like city or address.street or tags[1]
+	Msg  string
+}
+
+func (e ParamErr) String() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return e.Path + ": " + e.Msg
+}
+
+// ValidationErr is returned when the arguments of a call don't match the parameters schema,
+// the messages are meant to be returned to the LLM so that it can correct the call
+type ValidationErr struct {
+	Name   string // the function name
+	Errors []ParamErr
+}
+
+func (e ValidationErr) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, pe := range e.Errors {
+		msgs[i] = pe.String()
+	}
+	return fmt.Sprintf("invalid arguments for function %s: %s", e.Name, strings.Join(msgs, "; "))
+}
+
+// ToSchema converts the parameters of a Definition to *Schema,
+// parameters loaded from json are map[string]any and converted by json encoding
+func ToSchema(parameters any) (*Schema, error) {
+	switch p := parameters.(type) {
+	case nil:
+		return nil, nil
+	case *Schema:
+		return p, nil
+	case Schema:
+		return &p, nil
+	}
+	b, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, err
+	}
+	s := new(Schema)
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate validates a json decoded value against the schema.
+// It supports type, enum, required, minimum, maximum, minLength, maxLength, pattern,
+// items and additionalProperties, unknown types and formats are ignored
+func (s *Schema) Validate(v any) []ParamErr {
+	var errs []ParamErr
+	s.validate("", v, &errs)
+	return errs
+}
+
+// ValidateJSON validates a json string against the schema
+func (s *Schema) ValidateJSON(data string) []ParamErr {
+	return s.validateJSON("", data)
+}
+
+// validateJSON validates a json string, path is the path of the value
+func (s *Schema) validateJSON(path, data string) []ParamErr {
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return []ParamErr{{Path: path, Msg: fmt.Sprintf("invalid json %s", data)}}
+	}
+	var errs []ParamErr
+	s.validate(path, v, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v any, errs *[]ParamErr) {
+	if s == nil {
+		return
+	}
+	addErr := func(format string, a ...any) {
+		*errs = append(*errs, ParamErr{Path: path, Msg: fmt.Sprintf(format, a...)})
+	}
+	if !matchType(s.Type, v) {
+		addErr("expected %s, got %s", s.Type, jsonType(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		addErr("%s is not one of %s", jsonString(v), jsonString(s.Enum))
+	}
+	switch v := v.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			addErr("%v is less than the minimum %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			addErr("%v is greater than the maximum %v", v, *s.Maximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			addErr("length %d is less than the minLength %d", n, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			addErr("length %d is greater than the maxLength %d", n, *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			// invalid patterns are ignored
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+				addErr("%q does not match the pattern %s", v, s.Pattern)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			// properties with a default are filled before the call
+			if _, ok := v[name]; !ok && (s.Properties[name] == nil || s.Properties[name].Default == nil) {
+				*errs = append(*errs, ParamErr{Path: joinPath(path, name), Msg: "missing required property"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if ps, ok := s.Properties[name]; ok {
+				ps.validate(joinPath(path, name), v[name], errs)
+				continue
+			}
+			switch ap := s.AdditionalProperties.(type) {
+			case bool:
+				if !ap {
+					*errs = append(*errs, ParamErr{Path: joinPath(path, name), Msg: "unknown property"})
+				}
+			case *Schema:
+				ap.validate(joinPath(path, name), v[name], errs)
+			case map[string]any:
+				// decoded from json
+				if as, err := ToSchema(ap); err == nil {
+					as.validate(joinPath(path, name), v[name], errs)
+				}
+			}
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// matchType reports whether v is of the json type t, unknown types always match
+func matchType(t string, v any) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "null":
+		return v == nil
+	default:
+		return true
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// inEnum compares by json encoding since enum values may be golang values like int
+func inEnum(enum []any, v any) bool {
+	s := jsonString(v)
+	for _, e := range enum {
+		if jsonString(e) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonString(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// unknownFields returns the errors of the top-level properties of the struct argument which are not in the schema,
+// since they would be dropped silently by decoding. They are left to the schema if it rejects additional properties
+func (f *Function) unknownFields(data string) []ParamErr {
+	if ap, ok := f.schema.AdditionalProperties.(bool); len(f.schema.Properties) == 0 || ok && !ap {
+		return nil
+	}
+	var named map[string]json.RawMessage
+	if json.Unmarshal([]byte(data), &named) != nil {
+		return nil
+	}
+	var errs []ParamErr
+	for name := range named {
+		if _, ok := f.schema.Properties[name]; !ok {
+			errs = append(errs, ParamErr{Path: name, Msg: "unknown property"})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
@@ -0,0 +1,102 @@
+package function
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type Booking struct {
+	City   string   `json:"city" minLength:"1"`
+	Code   string   `json:"code,omitempty" pattern:"^[A-Z]{3}$"`
+	Nights int      `json:"nights" minimum:"1" maximum:"30"`
+	Room   string   `json:"room" enum:"single,double" default:"single"`
+	Guests []string `json:"guests,omitempty"`
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s := SchemaOf(reflect.TypeOf(Booking{}))
+	// tags apply to the field itself, not the items
+	maxLength := 8
+	s.Properties["guests"].Items.MaxLength = &maxLength
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{"valid", `{"city":"Paris","code":"CDG","nights":2,"room":"double","guests":["amy"]}`, nil},
+		{"default", `{"city":"Paris","nights":2}`, nil},
+		{"type", `{"city":1,"nights":1.5}`, []string{"city: expected string, got number", "nights: expected integer, got number"}},
+		{"required", `{"nights":2}`, []string{"city: missing required property"}},
+		{"empty", `{"city":"","nights":2}`, []string{"city: length 0 is less than the minLength 1"}},
+		{"range", `{"city":"Paris","nights":31}`, []string{"nights: 31 is greater than the maximum 30"}},
+		{"enum", `{"city":"Paris","nights":2,"room":"suite"}`, []string{`room: "suite" is not one of ["single","double"]`}},
+		{"pattern", `{"city":"Paris","nights":2,"code":"cdg"}`, []string{`code: "cdg" does not match the pattern ^[A-Z]{3}$`}},
+		{"items", `{"city":"Paris","nights":2,"guests":["amy","bartholomew"]}`, []string{"guests[1]: length 11 is greater than the maxLength 8"}},
+		{"additional", `{"city":"Paris","nights":2,"pet":"cat"}`, []string{"pet: unknown property"}},
+		{"invalid", `{`, []string{"invalid json {"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, pe := range s.ValidateJSON(tt.args) {
+				got = append(got, pe.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaValidateGeneric(t *testing.T) {
+	// parameters loaded from a def file
+	s, err := ToSchema(map[string]any{
+		"type":                 "object",
+		"properties":           map[string]any{"n": map[string]any{"type": "int"}},
+		"additionalProperties": map[string]any{"type": "boolean"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unknown type is ignored
+	if errs := s.ValidateJSON(`{"n":"x","ok":true}`); len(errs) != 0 {
+		t.Errorf("ValidateJSON() = %v", errs)
+	}
+	if errs := s.ValidateJSON(`{"ok":1}`); len(errs) != 1 || errs[0].Path != "ok" {
+		t.Errorf("ValidateJSON() = %v", errs)
+	}
+}
+
+func TestGetCallableValidate(t *testing.T) {
+	book, err := CreateFunction(func(b Booking) int { return b.Nights }, Definition{Name: "book"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = book.GetCallable(NewStructParams(`{"city":"Paris","nights":0}`))
+	var validationErr ValidationErr
+	if !errors.As(err, &validationErr) || validationErr.Name != "book" || len(validationErr.Errors) != 1 {
+		t.Fatalf("GetCallable() error = %v, want ValidationErr", err)
+	}
+
+	f, err := CreateFunction(describe, Definition{Name: "describe"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.BindArgs(`{"name":"p","x":1.5,"y":2,"arg4":"yes"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.GetCallable(p)
+	want := "invalid arguments for function describe: x: expected integer, got number; arg4: expected boolean, got string"
+	if err == nil || err.Error() != want {
+		t.Errorf("GetCallable() error = %v, want %s", err, want)
+	}
+
+	_, err = f.BindArgs(`{"name":"p","z":1}`)
+	want = "invalid arguments for function describe: x: missing required property; y: missing required property; " +
+		"arg4: missing required property; z: unknown property"
+	if err == nil || err.Error() != want {
+		t.Errorf("BindArgs() error = %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,82 @@
+package nlcall
+
+import (
+	"context"
+	"fmt"
+	"github.com/HFrost0/nlcall/function"
+	"reflect"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// Provider provides the value of an injectable parameter for a call,
+// per request values like the user or session can be taken from ctx
+type Provider func(ctx context.Context) (any, error)
+
+// Provide registers the provider of parameters whose type is exactly typ, e.g. loggers or DB handles.
+// These parameters are excluded from the definition and injected when called.
+// context.Context is always provided with the ctx of the call.
+// Providers should be registered before the functions using them.
+func (a *Agent) Provide(typ reflect.Type, provider Provider) {
+	a.providersMu.Lock()
+	defer a.providersMu.Unlock()
+	a.providers[typ] = provider
+}
+
+// ProvideValue registers v as the value of parameters of its type
+func (a *Agent) ProvideValue(v any) {
+	a.Provide(reflect.TypeOf(v), func(ctx context.Context) (any, error) {
+		return v, nil
+	})
+}
+
+// injectIdx returns the indexes of the parameters of fn which can be injected
+func (a *Agent) injectIdx(fn any) []int {
+	a.providersMu.RLock()
+	defer a.providersMu.RUnlock()
+	ft := reflect.TypeOf(fn)
+	var idx []int
+	for i := 0; i < ft.NumIn(); i++ {
+		if i == ft.NumIn()-1 && ft.IsVariadic() {
+			break
+		}
+		if _, ok := a.providers[ft.In(i)]; ok {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// Inject resolves the ignored parameters of f by the providers, the result is the ignoreParams of function.Callable
+func (a *Agent) Inject(ctx context.Context, f *function.Function) ([]any, error) {
+	ft := reflect.TypeOf(f.GetFn())
+	ignoreParams := make([]any, 0, len(f.GetIgnoreIdx()))
+	for _, idx := range f.GetIgnoreIdx() {
+		typ := ft.In(idx)
+		a.providersMu.RLock()
+		provider, ok := a.providers[typ]
+		a.providersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("no provider of %s for parameter %d of function %s", typ, idx, f.GetName())
+		}
+		v, err := provider(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("provide %s for function %s: %w", typ, f.GetName(), err)
+		}
+		ignoreParams = append(ignoreParams, v)
+	}
+	return ignoreParams, nil
+}
+
+// injectCallable passes the injected parameters when the callable is called without ignoreParams
+func injectCallable(injected []any, callable function.Callable) function.Callable {
+	if len(injected) == 0 {
+		return callable
+	}
+	return func(ignoreParams ...any) []any {
+		if len(ignoreParams) == 0 {
+			ignoreParams = injected
+		}
+		return callable(ignoreParams...)
+	}
+}
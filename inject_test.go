@@ -0,0 +1,119 @@
+package nlcall
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+type tenant struct {
+	name string
+}
+
+func greet(t *tenant, name string) string { return "hello " + name + " from " + t.name }
+
+var tenantType = reflect.TypeOf((*tenant)(nil))
+
+func newGreetAgent(t *testing.T, provider Provider) *Agent {
+	call := &function.Call{Name: "greet", Params: &function.Params{RawParams: []string{`"jack"`}}}
+	agent := NewAgent(&stubResolver{call: call}, nil)
+	agent.Provide(tenantType, provider)
+	if _, err := agent.RegisterFn(context.Background(), greet, WithName("greet"), WithDescription("greet someone")); err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+func TestAssignCallableInject(t *testing.T) {
+	agent := newGreetAgent(t, func(ctx context.Context) (any, error) {
+		return &tenant{name: "acme"}, nil
+	})
+	callable, err := agent.AssignCallable(context.Background(), "greet jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := callable(); res[0] != "hello jack from acme" {
+		t.Errorf("callable() = %v", res)
+	}
+}
+
+func TestAssignCallableProviderErr(t *testing.T) {
+	providerErr := errors.New("no tenant")
+	agent := newGreetAgent(t, func(ctx context.Context) (any, error) {
+		return nil, providerErr
+	})
+	// the provider error is returned instead of raised by the callable
+	if _, err := agent.AssignCallable(context.Background(), "greet jack"); !errors.Is(err, providerErr) {
+		t.Errorf("AssignCallable() error = %v, want %v", err, providerErr)
+	}
+}
+
+func TestProvideConcurrent(t *testing.T) {
+	agent := newGreetAgent(t, func(ctx context.Context) (any, error) {
+		return &tenant{name: "acme"}, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			agent.ProvideValue(&tenant{name: "acme"})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := agent.Call(context.Background(), "greet jack"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAssignCallableSafeCall(t *testing.T) {
+	agent := newGreetAgent(t, func(ctx context.Context) (any, error) {
+		// the function panics on the nil tenant
+		return nil, nil
+	})
+	callable, err := agent.AssignCallable(context.Background(), "greet jack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var panicErr PanicErr
+	if _, err = callable.SafeCall(); !errors.As(err, &panicErr) {
+		t.Errorf("SafeCall() error = %v, want PanicErr", err)
+	}
+	// a mismatch of ignoreParams panics as well
+	if _, err = callable.SafeCall(1, 2); !errors.As(err, &panicErr) {
+		t.Errorf("SafeCall() error = %v, want PanicErr", err)
+	}
+}
+
+func TestRegisterFnLoadedDefInject(t *testing.T) {
+	// the definition was saved before tenant was injected
+	dir := t.TempDir()
+	def := &function.Definition{Name: "greet", Description: "greet someone", Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"t": map[string]any{"type": "object"}, "name": map[string]any{"type": "string"}},
+		"required":   []string{"t", "name"},
+	}}
+	if err := SaveDef(dir, "github.com/HFrost0/nlcall.greet", def, "", true); err != nil {
+		t.Fatal(err)
+	}
+	agent := NewAgent(&stubResolver{}, nil)
+	agent.ProvideValue(&tenant{name: "acme"})
+	f, err := agent.RegisterFn(context.Background(), greet, WithLoadDefDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := f.GetDef().Parameters.(*function.Schema)
+	if _, ok := schema.Properties["t"]; ok || !reflect.DeepEqual(schema.Required, []string{"name"}) {
+		t.Errorf("parameters = %v, want t removed", f.GetDef().Parameters)
+	}
+	if _, err = f.BindArgs(`{"name":"jack"}`); err != nil {
+		t.Errorf("BindArgs() error = %v", err)
+	}
+}
@@ -11,7 +11,36 @@ type Resolver interface {
 	Resolve(ctx context.Context, userInput string) (call *function.Call, err error)
 }
 
+// MultiResolver resolves the user input to several independent calls, which is used by Agent.CallAll
+type MultiResolver interface {
+	ResolveAll(ctx context.Context, userInput string) (calls []*function.Call, err error)
+}
+
+// TurnResolver resolves the next turn of a conversation, which is required by Agent.Run.
+// The messages contain the results of previous calls as tool messages
+type TurnResolver interface {
+	ResolveTurn(ctx context.Context, messages []*Message) (*Turn, error)
+}
+
+// ScopedResolver resolves the user input with only the functions named funcNames offered to the model,
+// which is used when the functions are restricted by CallOption
+type ScopedResolver interface {
+	ResolveScoped(ctx context.Context, userInput string, funcNames []string) (*function.Call, error)
+}
+
+// CandidateResolver resolves the user input to at most k candidate calls ranked by their scores,
+// which is used by Agent.AssignCandidates
+type CandidateResolver interface {
+	ResolveCandidates(ctx context.Context, userInput string, k int) ([]*Candidate, error)
+}
+
 // Definer defines a function from golang func
 type Definer interface {
 	Define(ctx context.Context, fn any) (*function.Definition, error)
 }
+
+// InfoDefiner defines a function from its details and parameters schema,
+// it's required for functions whose details can't be found from the func value like methods
+type InfoDefiner interface {
+	DefineInfo(ctx context.Context, fnInfo *function.FuncInfo, schema *function.Schema) (*function.Definition, error)
+}
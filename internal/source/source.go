@@ -0,0 +1,163 @@
+// Package source parses the functions of a package from source files for the nlcall commands
+package source
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+// Directive annotates the functions to be processed by the nlcall commands
+const Directive = "//nlcall:func"
+
+// Package is a parsed package
+type Package struct {
+	Name  string
+	Funcs []*Func
+}
+
+// Func is a top level function or a method of the package
+type Func struct {
+	Info *function.FuncInfo
+	Decl *ast.FuncDecl
+	Recv string           // receiver type of methods like T or *T
+	sig  *types.Signature // nil if the function failed to type check
+}
+
+// Expr returns the expression of the function, method expressions like (*T).M for methods
+func (f *Func) Expr() string {
+	switch {
+	case f.Recv == "":
+		return f.Info.Name
+	case strings.HasPrefix(f.Recv, "*"):
+		return "(" + f.Recv + ")." + f.Info.Name
+	default:
+		return f.Recv + "." + f.Info.Name
+	}
+}
+
+// DefName returns the name of the function definition, methods are namespaced by their receiver type like T.M
+func (f *Func) DefName() string {
+	if f.Recv == "" {
+		return f.Info.Name
+	}
+	return strings.TrimPrefix(f.Recv, "*") + "." + f.Info.Name
+}
+
+// Parse parses the package in dir, files matching skip are ignored.
+// Only the annotated functions and methods are collected unless all is true.
+func Parse(dir string, skip func(name string) bool, all bool) (*Package, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info fs.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && (skip == nil || !skip(name))
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected exactly one package in %s, found %d", dir, len(pkgs))
+	}
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	res := &Package{Name: pkg.Name}
+	defs := typeCheck(fset, pkg)
+	for fileName, file := range pkg.Files {
+		src, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			d, ok := decl.(*ast.FuncDecl)
+			if !ok || d.Name.Name == "init" || d.Name.Name == "main" {
+				continue
+			}
+			// methods are only collected when annotated
+			recv := recvType(d)
+			if (!all || d.Recv != nil) && !hasDirective(d) {
+				continue
+			}
+			if d.Recv != nil && recv == "" {
+				continue
+			}
+			f := &Func{
+				Info: function.FuncInfoFromDecl(fset, string(src), d),
+				Decl: d,
+				Recv: recv,
+			}
+			if obj, ok := defs[d.Name].(*types.Func); ok {
+				f.sig = obj.Type().(*types.Signature)
+			}
+			res.Funcs = append(res.Funcs, f)
+		}
+	}
+	sort.Slice(res.Funcs, func(i, j int) bool {
+		return res.Funcs[i].DefName() < res.Funcs[j].DefName()
+	})
+	return res, nil
+}
+
+// typeCheck type checks the package with its imports from source so that the types of other packages are known,
+// the errors are ignored and the types which failed to type check are unknown
+func typeCheck(fset *token.FileSet, pkg *ast.Package) map[*ast.Ident]types.Object {
+	files := make([]*ast.File, 0, len(pkg.Files))
+	for _, file := range pkg.Files {
+		files = append(files, file)
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) {},
+	}
+	info := &types.Info{Defs: make(map[*ast.Ident]types.Object)}
+	_, _ = conf.Check(pkg.Name, fset, files, info)
+	return info.Defs
+}
+
+// ParametersSchema generates the parameters schema of fn as function.ParametersSchema does at runtime,
+// context.Context parameters are ignored
+func (p *Package) ParametersSchema(fn *Func) *function.Schema {
+	if fn.sig == nil {
+		return &function.Schema{Type: "object", Properties: make(map[string]*function.Schema)}
+	}
+	return function.SignatureSchema(fn.sig, fn.Info.ParamNames)
+}
+
+func hasDirective(fn *ast.FuncDecl) bool {
+	if fn.Doc == nil {
+		return false
+	}
+	for _, c := range fn.Doc.List {
+		if strings.TrimSpace(c.Text) == Directive {
+			return true
+		}
+	}
+	return false
+}
+
+// recvType returns the receiver type of a method like T or *T, "" for functions and generic receivers
+func recvType(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	switch t := fn.Recv.List[0].Type.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			return "*" + id.Name
+		}
+	}
+	return ""
+}
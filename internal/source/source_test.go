@@ -0,0 +1,91 @@
+package source
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+const testSrc = `package shop
+
+import "context"
+
+type Item struct {
+	Name  string ` + "`json:\"name\" description:\"the item name\"`" + `
+	Count int    ` + "`json:\"count,omitempty\" minimum:\"1\"`" + `
+}
+
+type Order struct {
+	Items []Item ` + "`json:\"items\"`" + `
+	Note  string ` + "`json:\"-\"`" + `
+}
+
+//nlcall:func
+func place(ctx context.Context, order Order) error { return nil }
+
+//nlcall:func
+func price(name string, qty, discount int, tags ...string) float64 { return 0 }
+
+func helper() {}
+`
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shop.go"), []byte(testSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := Parse(dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Name != "shop" || len(pkg.Funcs) != 2 {
+		t.Fatalf("Parse() = %s with %d funcs", pkg.Name, len(pkg.Funcs))
+	}
+
+	place, price := pkg.Funcs[0], pkg.Funcs[1]
+	want := `{"type":"object","properties":{"items":{"type":"array","items":{"type":"object","properties":{` +
+		`"count":{"type":"integer","minimum":1},"name":{"type":"string","description":"the item name"}},` +
+		`"required":["name"],"additionalProperties":false}}},"required":["items"],"additionalProperties":false}`
+	if got := pkg.ParametersSchema(place).String(); got != want {
+		t.Errorf("ParametersSchema(place) = %s, want %s", got, want)
+	}
+
+	s := pkg.ParametersSchema(price)
+	if !reflect.DeepEqual(s.Required, []string{"name", "qty", "discount", "tags"}) {
+		t.Errorf("ParametersSchema(price) required = %v", s.Required)
+	}
+	if got := s.Properties["tags"].String(); got != `{"type":"array","items":{"type":"string"}}` {
+		t.Errorf("ParametersSchema(price) tags = %s", got)
+	}
+}
+
+const imageSrc = `package draw
+
+import "image"
+
+//nlcall:func
+func move(p image.Point, to *image.Rectangle) {}
+`
+
+func TestParametersSchemaNonLocalTypes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "draw.go"), []byte(imageSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := Parse(dir, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the schema from source is the one generated by reflection at runtime
+	want, err := function.ParametersSchema(func(p image.Point, to *image.Rectangle) {}, []string{"p", "to"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pkg.ParametersSchema(pkg.Funcs[0]).String(); got != want.String() {
+		t.Errorf("ParametersSchema(move) = %s, want %s", got, want)
+	}
+}
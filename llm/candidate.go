@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/HFrost0/nlcall"
+	"github.com/HFrost0/nlcall/function"
+)
+
+// ResolveCandidates resolves the user input to at most k candidate calls in descending order of their scores.
+// k choices are requested if the client is a MultiChoiceCompletionClient, otherwise the only choice is resolved.
+// The choices resolved to the same call are merged and each choice is weighted by the geometric mean of
+// the probabilities of its tokens if they are given for every choice, otherwise the choices are weighted equally,
+// then the score of a candidate is the share of its weight in all the choices including the invalid ones.
+// Invalid choices are not repaired, the error of the first choice is returned if none of them is valid
+func (r *Resolver) ResolveCandidates(ctx context.Context, userInput string, k int) ([]*nlcall.Candidate, error) {
+	messages, tools, err := r.prepare(ctx, []*MessageContent{{Role: "user", Content: userInput}}, false)
+	if err != nil {
+		return nil, err
+	}
+	var choices []*ChoiceContent
+	if r.multiChoiceClient != nil {
+		if choices, err = r.multiChoiceClient.CompleteN(ctx, messages, tools, k); err != nil {
+			return nil, err
+		}
+	} else {
+		choice, err := r.complete(ctx, messages, tools)
+		if err != nil {
+			return nil, err
+		}
+		choices = []*ChoiceContent{choice}
+	}
+
+	var candidates []*nlcall.Candidate
+	key2candidate := make(map[string]*nlcall.Candidate)
+	var firstErr error
+	weights := choiceWeights(choices)
+	total := 0.0
+	for i, choice := range choices {
+		w := weights[i]
+		total += w
+		var turn *nlcall.Turn
+		var err error
+		if r.completionWithToolClient != nil {
+			turn, err = r.toolTurn(choice, false)
+		} else {
+			turn, err = r.promptTurn(choice.Content, false)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		key := callKey(turn.Call)
+		if c, ok := key2candidate[key]; ok {
+			c.Score += w
+			continue
+		}
+		c := &nlcall.Candidate{Call: turn.Call, Score: w}
+		key2candidate[key] = c
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		var invalidErr *invalidOutputErr
+		if errors.As(firstErr, &invalidErr) {
+			return nil, invalidErr.err
+		}
+		if firstErr == nil {
+			return nil, errors.New("no choices returned")
+		}
+		return nil, firstErr
+	}
+	for _, c := range candidates {
+		c.Score /= total
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	if k > 0 && len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates, nil
+}
+
+// choiceWeights returns the geometric mean of the token probabilities of each choice,
+// the weights are all 1 if the probabilities are missing for any choice, e.g. tool calls of OpenAI have none,
+// since they are not comparable to the ones given
+func choiceWeights(choices []*ChoiceContent) []float64 {
+	weights := make([]float64, len(choices))
+	for i, choice := range choices {
+		if len(choice.LogProbs) == 0 {
+			for j := range weights {
+				weights[j] = 1
+			}
+			return weights
+		}
+		sum := 0.0
+		for _, lp := range choice.LogProbs {
+			sum += lp
+		}
+		weights[i] = math.Exp(sum / float64(len(choice.LogProbs)))
+	}
+	return weights
+}
+
+// callKey identifies the calls of the same function with the same arguments regardless of json formatting
+func callKey(call *function.Call) string {
+	params, err := call.Params.Eval(func(nested *function.Call) (string, error) {
+		b, err := json.Marshal(callKey(nested))
+		return string(b), err
+	})
+	if err != nil {
+		params = call.Params
+	}
+	args := make([]string, len(params.RawParams))
+	for i, raw := range params.RawParams {
+		buf := new(bytes.Buffer)
+		if json.Compact(buf, []byte(raw)) != nil {
+			args[i] = raw
+			continue
+		}
+		args[i] = buf.String()
+	}
+	return call.Name + "(" + strings.Join(args, ",") + ")"
+}
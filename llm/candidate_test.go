@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// multiChoiceClient replies the choices and records the requested n
+type multiChoiceClient struct {
+	scriptedClient
+	choices []*ChoiceContent
+	n       int
+}
+
+func (c *multiChoiceClient) CompleteN(ctx context.Context, messages []*MessageContent, tools []*Tool, n int) ([]*ChoiceContent, error) {
+	c.n = n
+	return c.choices, nil
+}
+
+func TestResolveCandidates(t *testing.T) {
+	client := &multiChoiceClient{choices: []*ChoiceContent{
+		{Content: "add(1,2)", LogProbs: []float64{-0.1, -0.1}},
+		{Content: "add(2,1)", LogProbs: []float64{-1}},
+		{Content: "add( 1, 2 )", LogProbs: []float64{-0.2}},
+		{Content: "sub(1,2)", LogProbs: []float64{-0.5}},
+	}}
+	agent := NewLlmAgent(client)
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := agent.AssignCandidates(context.Background(), "1 plus 2", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.n != 4 || len(candidates) != 2 {
+		t.Fatalf("AssignCandidates() = %d candidates of %d choices", len(candidates), client.n)
+	}
+	total := math.Exp(-0.1) + math.Exp(-1) + math.Exp(-0.2) + math.Exp(-0.5)
+	for i, want := range []struct {
+		args  string
+		score float64
+	}{
+		{"1,2", (math.Exp(-0.1) + math.Exp(-0.2)) / total},
+		{"2,1", math.Exp(-1) / total},
+	} {
+		c := candidates[i]
+		if args := strings.Join(c.Call.Params.RawParams, ","); args != want.args || math.Abs(c.Score-want.score) > 1e-9 {
+			t.Errorf("candidates[%d] = %s %v, want %s %v", i, args, c.Score, want.args, want.score)
+		}
+	}
+
+	if candidates, err = agent.AssignCandidates(context.Background(), "1 plus 2", 1); err != nil || len(candidates) != 1 {
+		t.Errorf("AssignCandidates() = %v, %v", candidates, err)
+	}
+
+	client.choices = []*ChoiceContent{{Content: "sub(1,2)"}}
+	if _, err = agent.AssignCandidates(context.Background(), "1 minus 2", 2); err == nil || !strings.Contains(err.Error(), "sub not found") {
+		t.Errorf("AssignCandidates() error = %v", err)
+	}
+}
+
+func TestResolveCandidatesMissingLogProbs(t *testing.T) {
+	// the choice without log-probabilities is not favored over the others
+	client := &multiChoiceClient{choices: []*ChoiceContent{
+		{Content: "add(1,2)", LogProbs: []float64{-0.1}},
+		{Content: "add(1,2)", LogProbs: []float64{-0.1}},
+		{Content: "add(2,1)"},
+	}}
+	agent := NewLlmAgent(client)
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := agent.AssignCandidates(context.Background(), "1 plus 2", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 || math.Abs(candidates[0].Score-2.0/3) > 1e-9 || math.Abs(candidates[1].Score-1.0/3) > 1e-9 {
+		t.Errorf("AssignCandidates() = %+v, want scores 2/3 and 1/3", candidates)
+	}
+}
+
+func TestResolveCandidatesSingleChoice(t *testing.T) {
+	agent := NewLlmAgent(&scriptedClient{outputs: []string{"add(1,2)"}})
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := agent.AssignCandidates(context.Background(), "1 plus 2", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 || candidates[0].Score != 1 {
+		t.Errorf("AssignCandidates() = %+v", candidates)
+	}
+}
@@ -1,20 +1,33 @@
 package llm
 
-import "github.com/HFrost0/nlcall/function"
+import (
+	"github.com/HFrost0/nlcall"
+	"github.com/HFrost0/nlcall/function"
+)
 
-type MessageContent struct {
-	Role    string
-	Content string
-}
+type MessageContent = nlcall.Message
 
 type ChoiceContent struct {
 	Content   string
 	ToolCalls []*ToolCall
+	LogProbs  []float64 // the log-probabilities of the tokens, nil if they are not given
 }
 
-type ToolCall struct {
-	Name string
-	Args string // should be a json string
-}
+type ToolCall = nlcall.ToolCall
 
 type Tool = function.Definition
+
+// ChunkContent is a chunk of a streamed choice
+type ChunkContent struct {
+	Content        string           // the delta of the content
+	ToolCallDeltas []*ToolCallDelta // the fragments of tool calls
+}
+
+// ToolCallDelta is a fragment of a streamed tool call, the fragments with the same Index make up a call.
+// ID and Name are usually only in the first fragment and Args is a piece of the json arguments
+type ToolCallDelta struct {
+	Index int
+	ID    string
+	Name  string
+	Args  string
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/HFrost0/nlcall/function"
 )
 
-var fnDefSysPromptTemplate = `Your task is to output a formatted json string that defines a golang function. you will receive a json string like:
+var fnDefSysPromptTemplate = `Your task is to output a formatted json string that describes a golang function. you will receive a json string like:
 {
 	"name": "<fn_name>",
 	"comments": "<fn_comments>",
@@ -15,15 +15,22 @@ var fnDefSysPromptTemplate = `Your task is to output a formatted json string tha
 }
 try to understand the info, your output should be a informative json string like:
 '''
-{"name":"greet","description":"return a person's greeting with his/her name and age. Call string example: greet(\"李宁\",15) or greet(\"jack\",14)","parameters":{"properties":{"age":{"description":"the person's age","type":"integer"},"name":{"description":"the person's name","type":"string"}},"type":"object"}}
-{"name":"no","description":"use this func if there's no suitable function for user's input or user's request is unrelated to the existed funcs. Calling example: no()","parameters":null}
-{"name":"add","description":"return the sum of integers. Calling example: add([1,1]) add([1,2,4]). be aware that the input must be a list of integers","parameters":{"properties":{"nums":{"description":"multiple integers which will be added together","type":"integers"}},"type":"object"}}
+{"name":"greet","description":"return a person's greeting with his/her name and age. Call string example: greet(\"李宁\",15) or greet(\"jack\",14)","parameters":{"name":"the person's name","age":"the person's age"}}
+{"name":"add","description":"return the sum of integers. Calling example: add([1,1]) add([1,2,4]). be aware that the input must be a list of integers","parameters":{"nums":"multiple integers which will be added together"}}
 '''
 follow the rules:
 1. output without any explanation.
 2. output the json string in one line.
+3. "parameters" maps each parameter name to its description, the parameter types are already known.
 `
 
+// description is the prose part of a function.Definition written by LLM
+type description struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Parameters  map[string]string `json:"parameters"`
+}
+
 type Definer struct {
 	completionClient CompletionClient
 	systemPrompt     string
@@ -36,11 +43,23 @@ func NewDefiner(completionClient CompletionClient) *Definer {
 	}
 }
 
+// Define defines a golang func, the parameters schema is generated by reflection
+// and LLM is only asked for the descriptions
 func (l *Definer) Define(ctx context.Context, fn any) (*function.Definition, error) {
 	fnInfo, err := function.GetFunctionDetails(fn)
 	if err != nil {
 		return nil, err
 	}
+	schema, err := function.ParametersSchema(fn, fnInfo.ParamNames, function.ContextIdx(fn)...)
+	if err != nil {
+		return nil, err
+	}
+	return l.DefineInfo(ctx, fnInfo, schema)
+}
+
+// DefineInfo defines a function by its FuncInfo and parameters schema,
+// it's used when the golang func is not available, e.g. for definitions generated ahead of time
+func (l *Definer) DefineInfo(ctx context.Context, fnInfo *function.FuncInfo, schema *function.Schema) (*function.Definition, error) {
 	funcMsg, err := json.Marshal(fnInfo)
 	if err != nil {
 		return nil, err
@@ -56,11 +75,24 @@ func (l *Definer) Define(ctx context.Context, fn any) (*function.Definition, err
 		return nil, fmt.Errorf("no choices returned")
 	}
 	respStr := choices[0].Content
-	// 创建函数定义
-	res := new(function.Definition)
-	err = json.Unmarshal([]byte(respStr), res)
+	desc := new(description)
+	err = json.Unmarshal([]byte(respStr), desc)
 	if err != nil {
 		return nil, err
 	}
+	// 创建函数定义
+	for name, d := range desc.Parameters {
+		// descriptions from struct tags are preferred
+		if p, ok := schema.Properties[name]; ok && p.Description == "" {
+			p.Description = d
+		}
+	}
+	res := &function.Definition{
+		Name:        desc.Name,
+		Description: desc.Description,
+	}
+	if len(schema.Properties) > 0 {
+		res.Parameters = schema
+	}
 	return res, nil
 }
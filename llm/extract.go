@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reasoningTags are the tags of reasoning blocks emitted by reasoning models like <think>...</think>
+var reasoningTags = []string{"think", "thinking", "reasoning"}
+
+// fences wrap calling strings in markdown or like the examples of the system prompt
+var fences = []string{"```", "'''"}
+
+var callNameRex = regexp.MustCompile(`[\w.]+\(`)
+
+// noneMarker begins the output of the model when none of the functions is suitable
+const noneMarker = "NONE:"
+
+// ExtractedCall is a calling string found in the output of the model
+type ExtractedCall struct {
+	Text   string
+	Inline bool // whether there is other text on its line, like a call quoted in an answer
+}
+
+// Extraction is the result of extracting calling strings from the output of the model
+type Extraction struct {
+	Calls     []*ExtractedCall // the calls of registered functions in order
+	Discarded []string         // the text thrown away like reasoning blocks, fences and prose
+}
+
+// Extract extracts calling strings from noisy output, reasoning blocks and fences are stripped
+// and the calls are scanned with balanced parentheses and quotes.
+// isFunc reports whether a name is a registered function, nil means any name
+func Extract(output string, isFunc func(name string) bool) *Extraction {
+	ext := &Extraction{}
+	text := ext.stripReasoning(output)
+	text = ext.stripFences(text)
+
+	prose := new(strings.Builder)
+	flush := func() {
+		ext.discard(prose.String())
+		prose.Reset()
+	}
+	for rest, offset := text, 0; ; {
+		loc := callNameRex.FindStringIndex(rest)
+		if loc == nil {
+			prose.WriteString(rest)
+			break
+		}
+		name := rest[loc[0] : loc[1]-1]
+		end := balancedEnd(rest, loc[1]-1)
+		if end < 0 || (isFunc != nil && !isFunc(name)) {
+			prose.WriteString(rest[:loc[1]])
+			rest, offset = rest[loc[1]:], offset+loc[1]
+			continue
+		}
+		prose.WriteString(rest[:loc[0]])
+		flush()
+		ext.Calls = append(ext.Calls, &ExtractedCall{
+			Text:   rest[loc[0]:end],
+			Inline: isInline(text, offset+loc[0], offset+end),
+		})
+		rest, offset = rest[end:], offset+end
+	}
+	flush()
+	return ext
+}
+
+// noSuitableReply returns the reply to the user after the none marker, ok is false if the output doesn't begin with it
+func noSuitableReply(output string) (reply string, ok bool) {
+	ext := &Extraction{}
+	text := strings.TrimSpace(ext.stripFences(ext.stripReasoning(output)))
+	if len(text) < len(noneMarker) || !strings.EqualFold(text[:len(noneMarker)], noneMarker) {
+		return "", false
+	}
+	return strings.TrimSpace(text[len(noneMarker):]), true
+}
+
+// isInline reports whether there is other text than backticks on the lines of text[start:end]
+func isInline(text string, start, end int) bool {
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	lineEnd := len(text)
+	if i := strings.IndexByte(text[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+	return strings.Trim(text[lineStart:start]+text[end:lineEnd], "` \t\r") != ""
+}
+
+// stripReasoning returns the output without reasoning blocks
+func stripReasoning(output string) string {
+	return strings.TrimSpace(new(Extraction).stripReasoning(output))
+}
+
+// stripReasoning removes the reasoning blocks, an unclosed block takes the rest of the output
+// and a closing tag without the opening one takes the output before it
+func (ext *Extraction) stripReasoning(text string) string {
+	for _, tag := range reasoningTags {
+		open, closing := "<"+tag+">", "</"+tag+">"
+		if i := strings.Index(text, closing); i >= 0 && !strings.Contains(text[:i], open) {
+			ext.discard(text[:i+len(closing)])
+			text = text[i+len(closing):]
+		}
+		for {
+			i := strings.Index(text, open)
+			if i < 0 {
+				break
+			}
+			j := strings.Index(text[i:], closing)
+			if j < 0 {
+				ext.discard(text[i:])
+				text = text[:i]
+				break
+			}
+			j += i + len(closing)
+			ext.discard(text[i:j])
+			text = text[:i] + text[j:]
+		}
+	}
+	return text
+}
+
+// stripFences removes fence lines like ```json and the fences around a call on the same line
+func (ext *Extraction) stripFences(text string) string {
+	lines := strings.Split(text, "\n")
+	res := lines[:0]
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		isFence := false
+		for _, fence := range fences {
+			if strings.HasPrefix(trimmed, fence) && strings.Count(trimmed, fence) == 1 {
+				isFence = true
+			}
+			line = strings.ReplaceAll(line, fence, "")
+		}
+		if isFence {
+			ext.discard(trimmed)
+			continue
+		}
+		res = append(res, line)
+	}
+	return strings.Join(res, "\n")
+}
+
+func (ext *Extraction) discard(s string) {
+	if s = strings.TrimSpace(s); s != "" {
+		ext.Discarded = append(ext.Discarded, s)
+	}
+}
+
+// balancedEnd returns the end of the call whose "(" is at open,
+// brackets in double-quoted strings are skipped and -1 is returned if the call is not closed
+func balancedEnd(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				if s[i] != ')' {
+					return -1
+				}
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	isFunc := func(name string) bool { return name == "add" || name == "greet" }
+	tests := []struct {
+		name      string
+		output    string
+		calls     []string
+		inline    []bool
+		discarded []string
+	}{
+		{"plain", "add(1,2)", []string{"add(1,2)"}, []bool{false}, nil},
+		{
+			"think and fence",
+			"<think>maybe sub(1,2)?\nno, add</think>Sure! Here is the call:\n```python\nadd(1, 2)\n```",
+			[]string{"add(1, 2)"}, []bool{false},
+			[]string{"<think>maybe sub(1,2)?\nno, add</think>", "```python", "```", "Sure! Here is the call:"},
+		},
+		{"prompt fence", "'''greet(\"jack\",14)'''", []string{`greet("jack",14)`}, []bool{false}, nil},
+		{
+			"quoted parentheses",
+			`greet("a) b(", 1) and add([1,(2)])`,
+			[]string{`greet("a) b(", 1)`, "add([1,(2)])"}, []bool{true, true},
+			[]string{"and"},
+		},
+		{"multiline", "add([1,\n 2])\nadd([3])", []string{"add([1,\n 2])", "add([3])"}, []bool{false, false}, nil},
+		{"unknown and unclosed", "sub(1,2) add(1,", nil, nil, []string{"sub(1,2) add(1,"}},
+		{"unclosed think", "add(1,2)<think>add(3,4)", []string{"add(1,2)"}, []bool{false}, []string{"<think>add(3,4)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ext := Extract(tt.output, isFunc)
+			var calls []string
+			var inline []bool
+			for _, c := range ext.Calls {
+				calls = append(calls, c.Text)
+				inline = append(inline, c.Inline)
+			}
+			if !reflect.DeepEqual(calls, tt.calls) || !reflect.DeepEqual(inline, tt.inline) {
+				t.Errorf("Extract() calls = %q %v, want %q %v", calls, inline, tt.calls, tt.inline)
+			}
+			if !reflect.DeepEqual(ext.Discarded, tt.discarded) {
+				t.Errorf("Extract() discarded = %q, want %q", ext.Discarded, tt.discarded)
+			}
+		})
+	}
+}
+
+func TestResolveNoisyOutput(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"<think>1 plus 2 is add(1,2)</think>\n```\nadd(1,2)\n```", "add(1,2) is 3."}}
+	var discarded []string
+	agent := NewLlmAgent(client, WithExtractionHook(func(output string, ext *Extraction) {
+		discarded = append(discarded, ext.Discarded...)
+	}))
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := agent.Run(context.Background(), "1 plus 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the quoted call in the answer is not made
+	if len(res.Steps) != 1 || res.Answer != "add(1,2) is 3." {
+		t.Errorf("Run() = %+v", res)
+	}
+	if len(discarded) != 4 || discarded[0] != "<think>1 plus 2 is add(1,2)</think>" {
+		t.Errorf("discarded = %q", discarded)
+	}
+}
@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+// DefIndex is an in-memory vector index of function definitions. A definition is embedded by the text
+// of its name, description and the descriptions of its parameters, which is done lazily by the next Search
+type DefIndex struct {
+	client  EmbeddingClient
+	mu      sync.Mutex
+	defs    []*function.Definition
+	vectors [][]float64 // the embedding of each definition, nil if not embedded yet
+	// held while the definitions not embedded yet are embedded, so that concurrent searches embed them once
+	embedMu sync.Mutex
+}
+
+func NewDefIndex(client EmbeddingClient) *DefIndex {
+	return &DefIndex{client: client}
+}
+
+// Add adds the definition to the index
+func (idx *DefIndex) Add(def *function.Definition) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.defs = append(idx.defs, def)
+	idx.vectors = append(idx.vectors, nil)
+}
+
+// Search returns at most k definitions in descending order of their cosine similarity to the query,
+// the definitions not embedded yet are embedded together with the query
+func (idx *DefIndex) Search(ctx context.Context, query string, k int) ([]*function.Definition, error) {
+	idx.embedMu.Lock()
+	idx.mu.Lock()
+	var pending []int
+	var texts []string
+	for i, v := range idx.vectors {
+		if v == nil {
+			pending = append(pending, i)
+			texts = append(texts, defText(idx.defs[i]))
+		}
+	}
+	idx.mu.Unlock()
+	if len(pending) == 0 {
+		// only the query is embedded, which doesn't block other searches
+		idx.embedMu.Unlock()
+	} else {
+		defer idx.embedMu.Unlock()
+	}
+
+	vectors, err := idx.client.Embed(ctx, append(texts, query))
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts)+1 {
+		return nil, fmt.Errorf("embedding count mismatch, %d texts but %d embeddings", len(texts)+1, len(vectors))
+	}
+	queryVec := vectors[len(texts)]
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for j, i := range pending {
+		idx.vectors[i] = vectors[j]
+	}
+	order := make([]int, len(idx.defs))
+	scores := make([]float64, len(idx.defs))
+	for i := range idx.defs {
+		order[i] = i
+		scores[i] = cosine(idx.vectors[i], queryVec)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	if k > 0 && len(order) > k {
+		order = order[:k]
+	}
+	defs := make([]*function.Definition, len(order))
+	for j, i := range order {
+		defs[j] = idx.defs[i]
+	}
+	return defs, nil
+}
+
+// defText returns the text to embed of the definition
+func defText(def *function.Definition) string {
+	lines := []string{def.Name, def.Description}
+	schema, err := function.ToSchema(def.Parameters)
+	if err == nil && schema != nil {
+		lines = appendParamDescriptions(lines, "", schema)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// appendParamDescriptions appends the descriptions of the properties in the schema like "city: the name of the city"
+func appendParamDescriptions(lines []string, path string, schema *function.Schema) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := schema.Properties[name]
+		p := name
+		if path != "" {
+			p = path + "." + name
+		}
+		if prop.Description != "" {
+			lines = append(lines, p+": "+prop.Description)
+		}
+		lines = appendParamDescriptions(lines, p, prop)
+		if prop.Items != nil {
+			lines = appendParamDescriptions(lines, p, prop.Items)
+		}
+	}
+	return lines
+}
+
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+// wordsClient embeds a text by the counts of the words and records the number of embedded texts
+type wordsClient struct {
+	words    []string
+	mu       sync.Mutex
+	embedded int
+}
+
+func (c *wordsClient) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	c.mu.Lock()
+	c.embedded += len(texts)
+	c.mu.Unlock()
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float64, len(c.words))
+		for j, w := range c.words {
+			vectors[i][j] = float64(strings.Count(strings.ToLower(text), w))
+		}
+	}
+	return vectors, nil
+}
+
+func TestDefIndex(t *testing.T) {
+	client := &wordsClient{words: []string{"weather", "city", "sum", "greet"}}
+	idx := NewDefIndex(client)
+	idx.Add(&function.Definition{Name: "add", Description: "sum of the numbers"})
+	idx.Add(&function.Definition{Name: "weather", Description: "the weather", Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string", "description": "the city"}},
+	}})
+	idx.Add(&function.Definition{Name: "greet", Description: "greet someone"})
+	defs, err := idx.Search(context.Background(), "is it raining in the city", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || defs[0].Name != "weather" {
+		t.Errorf("Search() = %v", defs)
+	}
+	// the definitions are embedded once
+	if _, err = idx.Search(context.Background(), "greet jack", 1); err != nil {
+		t.Fatal(err)
+	}
+	if client.embedded != 5 {
+		t.Errorf("embedded %d texts, want 5", client.embedded)
+	}
+}
+
+func TestResolvePreselect(t *testing.T) {
+	client := &scriptedClient{outputs: []string{`weather("Paris")`}}
+	agent := NewLlmAgent(client, WithPreselect(&wordsClient{words: []string{"weather", "sum"}}, 1))
+	weather, err := function.CreateFunction(func(city string) string { return city + " is sunny" },
+		function.Definition{Name: "weather", Description: "the weather of the city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*function.Function{newAddFunction(t), weather} {
+		if err = agent.RegisterFunc(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := agent.Call(context.Background(), "what's the weather in Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value() != "Paris is sunny" {
+		t.Errorf("Call() = %v", res.Value())
+	}
+	if sysPrompt := client.messages[0][0].Content; !strings.Contains(sysPrompt, `"name":"weather"`) || strings.Contains(sysPrompt, `"name":"add"`) {
+		t.Errorf("system prompt = %s", sysPrompt)
+	}
+}
+
+func TestDefIndexConcurrentSearch(t *testing.T) {
+	client := &wordsClient{words: []string{"weather", "sum"}}
+	idx := NewDefIndex(client)
+	idx.Add(&function.Definition{Name: "add", Description: "sum of the numbers"})
+	idx.Add(&function.Definition{Name: "weather", Description: "the weather"})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := idx.Search(context.Background(), "weather", 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// the definitions are embedded once besides every query
+	if client.embedded != 2+8 {
+		t.Errorf("embedded %d texts, want 10", client.embedded)
+	}
+}
+
+func TestResolvePreselectFollowUp(t *testing.T) {
+	client := &scriptedClient{outputs: []string{`weather("Paris")`}}
+	r := NewResolver(client, WithPreselect(&wordsClient{words: []string{"weather", "sum"}}, 1))
+	weather, err := function.CreateFunction(func(city string) string { return city + " is sunny" },
+		function.Definition{Name: "weather", Description: "the weather of the city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddFunc(newAddFunction(t))
+	r.AddFunc(weather)
+	s := NewSession()
+	s.Add(&MessageContent{Role: "user", Content: "weather in Beijing"}, &MessageContent{Role: "assistant", Content: `weather("Beijing")`})
+	// the follow-up alone is relevant to none of the functions
+	if _, err = r.ResolveSession(context.Background(), s, "and Paris"); err != nil {
+		t.Fatal(err)
+	}
+	if sysPrompt := client.messages[0][0].Content; !strings.Contains(sysPrompt, `"name":"weather"`) || strings.Contains(sysPrompt, `"name":"add"`) {
+		t.Errorf("system prompt = %s", sysPrompt)
+	}
+}
@@ -14,3 +14,22 @@ type CompletionWithToolClient interface {
 	CompletionClient
 	CompleteWithTool(ctx context.Context, messages []*MessageContent, tools []*Tool) ([]*ChoiceContent, error)
 }
+
+// StreamingCompletionClient streams the first choice of the completion, tools is nil if tool calling is not used.
+// onChunk is called for every chunk in order and streaming stops with the error returned by onChunk
+type StreamingCompletionClient interface {
+	CompletionClient
+	CompleteStream(ctx context.Context, messages []*MessageContent, tools []*Tool, onChunk func(chunk *ChunkContent) error) error
+}
+
+// MultiChoiceCompletionClient completes n choices with the log-probabilities of their tokens,
+// tools is nil if tool calling is not used
+type MultiChoiceCompletionClient interface {
+	CompletionClient
+	CompleteN(ctx context.Context, messages []*MessageContent, tools []*Tool, n int) ([]*ChoiceContent, error)
+}
+
+// EmbeddingClient embeds the texts to vectors in order
+type EmbeddingClient interface {
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
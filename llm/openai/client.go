@@ -0,0 +1,283 @@
+// Package openai provides a client of llm.CompletionWithToolClient, llm.StreamingCompletionClient and
+// llm.MultiChoiceCompletionClient for OpenAI compatible chat completion endpoints,
+// e.g. local servers like LM Studio, Ollama or vLLM
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/HFrost0/nlcall/function"
+	"github.com/HFrost0/nlcall/llm"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type Client struct {
+	*http.Client
+	Model       string
+	Headers     map[string]string
+	Url         string
+	Temperature float64
+	ReqMaxRetry int
+}
+
+// NewClient creates a client of the chat completion endpoint url like http://127.0.0.1:1234/v1/chat/completions
+func NewClient(url string, model string) *Client {
+	return &Client{
+		Url:         url,
+		ReqMaxRetry: 1,
+		Client:      &http.Client{},
+		Model:       model,
+		Temperature: 1.0,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+func (c *Client) Complete(ctx context.Context, messages []*llm.MessageContent) ([]*llm.ChoiceContent, error) {
+	return c.CompleteWithTool(ctx, messages, nil)
+}
+
+func (c *Client) CompleteWithTool(ctx context.Context, messages []*llm.MessageContent, tools []*llm.Tool) ([]*llm.ChoiceContent, error) {
+	return c.complete(ctx, c.newRequest(messages, tools))
+}
+
+// CompleteN completes n choices with the log-probabilities of their tokens
+func (c *Client) CompleteN(ctx context.Context, messages []*llm.MessageContent, tools []*llm.Tool, n int) ([]*llm.ChoiceContent, error) {
+	req := c.newRequest(messages, tools)
+	req.N = n
+	req.Logprobs = true
+	return c.complete(ctx, req)
+}
+
+func (c *Client) complete(ctx context.Context, req *request) ([]*llm.ChoiceContent, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < 1+c.ReqMaxRetry; i++ {
+		var respBytes []byte
+		respBytes, err = c.sendRequest(ctx, body)
+		if err != nil {
+			continue
+		}
+		var choices []*llm.ChoiceContent
+		choices, err = c.fromBytes(respBytes)
+		if err != nil {
+			continue
+		}
+		return choices, nil
+	}
+	return nil, fmt.Errorf("request failed after %d attempts: %w", 1+c.ReqMaxRetry, err)
+}
+
+// CompleteStream streams the first choice by server-sent events, the request is not retried
+// since the chunks may have been consumed
+func (c *Client) CompleteStream(ctx context.Context, messages []*llm.MessageContent, tools []*llm.Tool, onChunk func(chunk *llm.ChunkContent) error) error {
+	req := c.newRequest(messages, tools)
+	req.Stream = true
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+		chunk, err := chunkFromBytes([]byte(data))
+		if err != nil {
+			return err
+		}
+		if chunk == nil {
+			continue
+		}
+		if err = onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Client) sendRequest(ctx context.Context, body []byte) ([]byte, error) {
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// post sends the request body, the response body should be closed by the caller
+func (c *Client) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("response status code %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+type request struct {
+	Model       string     `json:"model"`
+	Tools       []*tool    `json:"tools,omitempty"`
+	Messages    []*message `json:"messages"`
+	Temperature float64    `json:"temperature"`
+	Stream      bool       `json:"stream"`
+	N           int        `json:"n,omitempty"`
+	Logprobs    bool       `json:"logprobs,omitempty"`
+}
+
+type tool struct {
+	Type     string               `json:"type"`
+	Function *function.Definition `json:"function"`
+}
+
+type message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type toolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type response struct {
+	Choices []struct {
+		Message  message `json:"message"`
+		Logprobs *struct {
+			Content []struct {
+				Logprob float64 `json:"logprob"`
+			} `json:"content"`
+		} `json:"logprobs"`
+	} `json:"choices"`
+}
+
+type streamResponse struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+func (c *Client) newRequest(messages []*llm.MessageContent, tools []*llm.Tool) *request {
+	req := &request{
+		Model:       c.Model,
+		Temperature: c.Temperature,
+	}
+	for _, msg := range messages {
+		m := &message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			t := toolCall{ID: tc.ID, Type: "function"}
+			t.Function.Name = tc.Name
+			t.Function.Arguments = tc.Args
+			m.ToolCalls = append(m.ToolCalls, t)
+		}
+		req.Messages = append(req.Messages, m)
+	}
+	for _, t := range tools {
+		req.Tools = append(req.Tools, &tool{
+			Type:     "function",
+			Function: t,
+		})
+	}
+	return req
+}
+
+func (c *Client) fromBytes(bytes []byte) ([]*llm.ChoiceContent, error) {
+	resp := &response{}
+	if err := json.Unmarshal(bytes, resp); err != nil {
+		return nil, err
+	}
+	var choices []*llm.ChoiceContent
+	for _, ch := range resp.Choices {
+		choice := &llm.ChoiceContent{
+			Content: ch.Message.Content,
+		}
+		if ch.Logprobs != nil {
+			for _, t := range ch.Logprobs.Content {
+				choice.LogProbs = append(choice.LogProbs, t.Logprob)
+			}
+		}
+		for _, tc := range ch.Message.ToolCalls {
+			choice.ToolCalls = append(choice.ToolCalls, &llm.ToolCall{
+				ID:   tc.ID,
+				Name: tc.Function.Name,
+				Args: tc.Function.Arguments,
+			})
+		}
+		choices = append(choices, choice)
+	}
+	return choices, nil
+}
+
+// chunkFromBytes converts an event of the stream to the chunk of the first choice, nil if there is none
+func chunkFromBytes(bytes []byte) (*llm.ChunkContent, error) {
+	resp := &streamResponse{}
+	if err := json.Unmarshal(bytes, resp); err != nil {
+		return nil, err
+	}
+	for _, ch := range resp.Choices {
+		if ch.Index != 0 {
+			continue
+		}
+		chunk := &llm.ChunkContent{Content: ch.Delta.Content}
+		for _, tc := range ch.Delta.ToolCalls {
+			chunk.ToolCallDeltas = append(chunk.ToolCallDeltas, &llm.ToolCallDelta{
+				Index: tc.Index,
+				ID:    tc.ID,
+				Name:  tc.Function.Name,
+				Args:  tc.Function.Arguments,
+			})
+		}
+		return chunk, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,122 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/HFrost0/nlcall/llm"
+)
+
+func TestChunkFromBytes(t *testing.T) {
+	chunk, err := chunkFromBytes([]byte(`{"choices":[{"index":0,"delta":{"content":"sure","tool_calls":[` +
+		`{"index":1,"id":"call_1","function":{"name":"add","arguments":"{\"a\":"}}]}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := chunk.ToolCallDeltas[0]
+	if chunk.Content != "sure" || tc.Index != 1 || tc.ID != "call_1" || tc.Name != "add" || tc.Args != `{"a":` {
+		t.Errorf("chunkFromBytes() = %+v %+v", chunk, tc)
+	}
+
+	// only the first choice is streamed
+	for _, data := range []string{`{"choices":[{"index":1,"delta":{"content":"other"}}]}`, `{"choices":[]}`} {
+		if chunk, err = chunkFromBytes([]byte(data)); err != nil || chunk != nil {
+			t.Errorf("chunkFromBytes(%s) = %+v, %v, want nil", data, chunk, err)
+		}
+	}
+	if _, err = chunkFromBytes([]byte(`{"choices":`)); err == nil {
+		t.Error("chunkFromBytes() of an invalid event should fail")
+	}
+}
+
+func TestCompleteStream(t *testing.T) {
+	events := []string{
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"add","arguments":""}}]}}]}`,
+		`{"choices":[{"index":1,"delta":{"content":"ignored"}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"a\":1,\"b\":2}"}}]}}]}`,
+		`[DONE]`,
+		`{"choices":[{"index":0,"delta":{"content":"after done"}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request = %+v, %v, want a streaming one", req, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		// comments and blank lines are not events
+		fmt.Fprint(w, ": keep-alive\n\n")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	var chunks []*llm.ChunkContent
+	client := NewClient(server.URL, "test")
+	err := client.CompleteStream(context.Background(), []*llm.MessageContent{{Role: "user", Content: "1 plus 2"}}, nil,
+		func(chunk *llm.ChunkContent) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args []string
+	for _, chunk := range chunks {
+		for _, delta := range chunk.ToolCallDeltas {
+			args = append(args, delta.Args)
+		}
+	}
+	if len(chunks) != 2 || chunks[0].ToolCallDeltas[0].Name != "add" || strings.Join(args, "") != `{"a":1,"b":2}` {
+		t.Errorf("CompleteStream() chunks = %d, args = %v", len(chunks), args)
+	}
+
+	// the error of onChunk stops the stream
+	stopErr := fmt.Errorf("stop")
+	sent := 0
+	err = client.CompleteStream(context.Background(), nil, nil, func(chunk *llm.ChunkContent) error {
+		sent++
+		return stopErr
+	})
+	if err != stopErr || sent != 1 {
+		t.Errorf("CompleteStream() = %v after %d chunks, want %v", err, sent, stopErr)
+	}
+}
+
+func TestCompleteStreamStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	err := NewClient(server.URL, "test").CompleteStream(context.Background(), nil, nil, func(chunk *llm.ChunkContent) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("CompleteStream() error = %v, want the status code", err)
+	}
+}
+
+func TestFromBytes(t *testing.T) {
+	c := NewClient("", "test")
+	choices, err := c.fromBytes([]byte(`{"choices":[` +
+		`{"message":{"role":"assistant","content":"add(1,2)"},"logprobs":{"content":[{"token":"add","logprob":-0.5},{"token":"(","logprob":0}]}},` +
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"add","arguments":"{}"}}]},"logprobs":null}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(choices) != 2 {
+		t.Fatalf("fromBytes() = %d choices, want 2", len(choices))
+	}
+	if lps := choices[0].LogProbs; choices[0].Content != "add(1,2)" || len(lps) != 2 || lps[0] != -0.5 || lps[1] != 0 {
+		t.Errorf("fromBytes() choices[0] = %+v", choices[0])
+	}
+	tc := choices[1].ToolCalls[0]
+	if choices[1].LogProbs != nil || tc.ID != "call_1" || tc.Name != "add" || tc.Args != "{}" {
+		t.Errorf("fromBytes() choices[1] = %+v %+v", choices[1], tc)
+	}
+}
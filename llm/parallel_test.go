@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/HFrost0/nlcall"
+	"github.com/HFrost0/nlcall/function"
+)
+
+// toolClient replies the tool calls with the content
+type toolClient struct {
+	scriptedClient
+	content   string
+	toolCalls []*ToolCall
+}
+
+func (c *toolClient) CompleteWithTool(ctx context.Context, messages []*MessageContent, tools []*Tool) ([]*ChoiceContent, error) {
+	return []*ChoiceContent{{Content: c.content, ToolCalls: c.toolCalls}}, nil
+}
+
+func TestAgentCallAll(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	// closed once two calls are running, which are blocked until then
+	bothRunning := make(chan struct{})
+	var once sync.Once
+	weather := func(city string) string {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		if running == 2 {
+			once.Do(func() { close(bothRunning) })
+		}
+		mu.Unlock()
+		select {
+		case <-bothRunning:
+		case <-time.After(time.Second):
+		}
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "sunny in " + city
+	}
+	f, err := function.CreateFunction(weather, function.Definition{Name: "weather"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &toolClient{}
+	for i, city := range []string{"Tokyo", "Paris", "Rome", "Oslo"} {
+		client.toolCalls = append(client.toolCalls, &ToolCall{ID: string(rune('a' + i)), Name: "weather", Args: `{"city":"` + city + `"}`})
+	}
+	agent := NewLlmAgent(client)
+	if err = agent.RegisterFunc(f); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := agent.CallAll(context.Background(), "weather in Tokyo, Paris, Rome and Oslo", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, step := range steps {
+		if step.Err != nil {
+			t.Fatal(step.Err)
+		}
+		results = append(results, step.Result.Value().(string))
+	}
+	if got := strings.Join(results, ","); got != "sunny in Tokyo,sunny in Paris,sunny in Rome,sunny in Oslo" {
+		t.Errorf("CallAll() = %s", got)
+	}
+	select {
+	case <-bothRunning:
+	default:
+		t.Error("CallAll() never ran 2 calls at the same time")
+	}
+	if maxRunning > 2 {
+		t.Errorf("CallAll() ran %d calls at the same time, want at most 2", maxRunning)
+	}
+}
+
+// turnsClient replies the choices in order by tool calling and records the received messages
+type turnsClient struct {
+	scriptedClient
+	choices []*ChoiceContent
+}
+
+func (c *turnsClient) CompleteWithTool(ctx context.Context, messages []*MessageContent, tools []*Tool) ([]*ChoiceContent, error) {
+	c.messages = append(c.messages, messages)
+	choice := c.choices[0]
+	c.choices = c.choices[1:]
+	return []*ChoiceContent{choice}, nil
+}
+
+func TestAgentRunParallelToolCalls(t *testing.T) {
+	client := &turnsClient{choices: []*ChoiceContent{
+		{ToolCalls: []*ToolCall{
+			{ID: "a", Name: "add", Args: `{"a":1,"b":2}`},
+			{ID: "b", Name: "add", Args: `{"a":3,"b":4}`},
+		}},
+		{Content: "3 and 7"},
+	}}
+	agent := NewLlmAgent(client)
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := agent.Run(context.Background(), "what are 1+2 and 3+4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Steps) != 2 || res.Steps[0].Result.Value() != 3 || res.Steps[1].Result.Value() != 7 {
+		t.Fatalf("Run() steps = %v", res.Steps)
+	}
+	// user, the calls, a result for each of them and the answer
+	if len(res.Messages) != 5 || res.Messages[2].ToolCallID != "a" || res.Messages[3].ToolCallID != "b" ||
+		res.Messages[3].Content != "7" || res.Answer != "3 and 7" {
+		t.Errorf("Run() messages = %v", res.Messages)
+	}
+
+	// the calls made at once don't exceed the max steps
+	client.choices = []*ChoiceContent{{ToolCalls: []*ToolCall{
+		{ID: "a", Name: "add", Args: `{"a":1,"b":2}`},
+		{ID: "b", Name: "add", Args: `{"a":3,"b":4}`},
+	}}}
+	if res, err = agent.Run(context.Background(), "what are 1+2 and 3+4", nlcall.WithMaxSteps(1)); !errors.Is(err, nlcall.MaxStepsErr) || len(res.Steps) != 0 {
+		t.Errorf("Run() = %v, %v, want MaxStepsErr", res, err)
+	}
+}
+
+func TestResolveAllByPrompt(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"add(1,2)\nadd(3,4)"}}
+	r := NewResolver(client)
+	r.AddFunc(newAddFunction(t))
+	calls, err := r.ResolveAll(context.Background(), "1+2 and 3+4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || strings.Join(calls[1].Params.RawParams, ",") != "3,4" {
+		t.Errorf("ResolveAll() = %v", calls)
+	}
+}
@@ -0,0 +1,267 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF     tokenKind = iota
+	tokenIdent             // function names and true, false, null
+	tokenString            // json string
+	tokenNumber            // json number
+	tokenPunct             // ( ) [ ] { } , :
+	tokenInvalid           // the rest of the source which can't be tokenized
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	start int // the byte offset in the source
+	end   int
+}
+
+// tokenize splits a calling string into tokens, the validity of strings and numbers is checked by the parser.
+// Tokenizing stops at an invalid character since the text after the call is ignored
+func tokenize(src string) []token {
+	var tokens []token
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case strings.ContainsRune("()[]{},:=", r):
+			i += size
+			tokens = append(tokens, token{kind: tokenPunct, text: src[start:i], start: start, end: i})
+		case r == '"':
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(src) {
+				return append(tokens, token{kind: tokenInvalid, text: src[start:], start: start, end: len(src)})
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: src[start:i], start: start, end: i})
+		case r == '-' || r == '+' || unicode.IsDigit(r):
+			i += size
+			for i < len(src) && strings.IndexByte("0123456789.eE+-", src[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], start: start, end: i})
+		case r == '_' || unicode.IsLetter(r):
+			for i < len(src) {
+				r, size = utf8.DecodeRuneInString(src[i:])
+				if r != '_' && r != '.' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], start: start, end: i})
+		default:
+			return append(tokens, token{kind: tokenInvalid, text: src[start:], start: start, end: len(src)})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, start: len(src), end: len(src)})
+}
+
+// parser is a recursive descent parser of calling strings:
+//
+//	call  = name "(" [arg {"," arg}] ")"
+//	arg   = [name "="] value
+//	value = call | json literal | "[" [value {"," value}] "]" | "{" [string ":" value {"," string ":" value}] "}"
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+	// bind binds the arguments with keywords of a call to its parameters
+	bind func(fnName string, args []*function.Expr, names []string) (*function.Params, error)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF && t.kind != tokenInvalid {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(punct string) error {
+	if t := p.next(); t.kind != tokenPunct || t.text != punct {
+		return p.unexpected(t, punct)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token, want string) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("expected %s but reached the end", want)
+	}
+	return fmt.Errorf("expected %s but got %s at %d", want, t.text, t.start)
+}
+
+func (p *parser) isPunct(punct string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == punct
+}
+
+func (p *parser) parseCall() (*function.Call, error) {
+	name := p.next()
+	if name.kind != tokenIdent {
+		return nil, p.unexpected(name, "function name")
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []*function.Expr
+	var names []string // keywords of the arguments, "" for positional ones
+	keyword := false
+	for !p.isPunct(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		argName := ""
+		if t := p.peek(); t.kind == tokenIdent && p.tokens[p.pos+1].text == "=" {
+			argName = t.text
+			keyword = true
+			p.pos += 2
+		}
+		arg, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		names = append(names, argName)
+	}
+	p.next()
+	if !keyword {
+		return &function.Call{Name: name.text, Params: function.NewExprParams(args)}, nil
+	}
+	if p.bind == nil {
+		return nil, fmt.Errorf("keyword arguments are not supported for function %s", name.text)
+	}
+	params, err := p.bind(name.text, args, names)
+	if err != nil {
+		return nil, err
+	}
+	return &function.Call{Name: name.text, Params: params}, nil
+}
+
+func (p *parser) parseValue() (*function.Expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenIdent && p.tokens[p.pos+1].text == "(":
+		call, err := p.parseCall()
+		if err != nil {
+			return nil, err
+		}
+		return &function.Expr{Call: call}, nil
+	case t.kind == tokenIdent || t.kind == tokenString || t.kind == tokenNumber:
+		p.next()
+		if !json.Valid([]byte(t.text)) {
+			return nil, fmt.Errorf("invalid value %s at %d", t.text, t.start)
+		}
+		return &function.Expr{JSON: t.text}, nil
+	case p.isPunct("["):
+		p.next()
+		var items []*function.Expr
+		for !p.isPunct("]") {
+			if len(items) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		end := p.next()
+		if !function.AnyNested(items) {
+			return p.literal(t.start, end.end)
+		}
+		return &function.Expr{Items: items}, nil
+	case p.isPunct("{"):
+		p.next()
+		var fields []*function.Field
+		var values []*function.Expr
+		for !p.isPunct("}") {
+			if len(fields) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			key := p.next()
+			var k string
+			if key.kind != tokenString || json.Unmarshal([]byte(key.text), &k) != nil {
+				return nil, p.unexpected(key, "object key")
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, &function.Field{Key: k, Value: value})
+			values = append(values, value)
+		}
+		end := p.next()
+		if !function.AnyNested(values) {
+			return p.literal(t.start, end.end)
+		}
+		return &function.Expr{Fields: fields}, nil
+	default:
+		return nil, p.unexpected(t, "value")
+	}
+}
+
+// literal returns the json value of the source between start and end, which is compacted
+func (p *parser) literal(start, end int) (*function.Expr, error) {
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, []byte(p.src[start:end])); err != nil {
+		return nil, fmt.Errorf("invalid value %s at %d", p.src[start:end], start)
+	}
+	return &function.Expr{JSON: buf.String()}, nil
+}
+
+// callStartRex matches the start of a calling string,
+// names of methods registered by nlcall.Agent.RegisterObject are namespaced like Calendar.CreateEvent
+var callStartRex = regexp.MustCompile(`[\w.]+\(`)
+
+// parseFuncStr parses the first calling string of the output like "funcName(param1,param2,...)",
+// the parameters are json values or nested calls like mul([add([1,2]),3]).
+// Keyword arguments like greet(name="jack",age=14) are bound by bind
+func parseFuncStr(funcStr string, bind func(fnName string, args []*function.Expr, names []string) (*function.Params, error)) (call *function.Call, err error) {
+	loc := callStartRex.FindStringIndex(funcStr)
+	if loc == nil {
+		return nil, fmt.Errorf("invalid funcStr %s", funcStr)
+	}
+	src := funcStr[loc[0]:]
+	p := &parser{src: src, tokens: tokenize(src), bind: bind}
+	if call, err = p.parseCall(); err != nil {
+		return nil, fmt.Errorf("invalid parameters to parse: %s: %w", src, err)
+	}
+	return call, nil
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HFrost0/nlcall/function"
+)
+
+func TestParseFuncStr(t *testing.T) {
+	tests := []struct {
+		name    string
+		funcStr string
+		want    []string
+		wantErr bool
+	}{
+		{"json", `greet("李宁",15)`, []string{`"李宁"`, "15"}, false},
+		{"no args", "no()", []string{}, false},
+		{"spaces", `'''add( [1, 2,3] , {"a": [true, null]} )'''`, []string{"[1,2,3]", `{"a":[true,null]}`}, false},
+		{"method", `Calendar.CreateEvent("a(b)")`, []string{`"a(b)"`}, false},
+		{"unclosed", "add([1,2)", nil, true},
+		{"invalid value", "add(01)", nil, true},
+		{"no call", "I don't know", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call, err := parseFuncStr(tt.funcStr, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFuncStr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(call.Params.RawParams, tt.want) {
+				t.Errorf("parseFuncStr() = %v, want %v", call.Params.RawParams, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNestedFuncStr(t *testing.T) {
+	call, err := parseFuncStr(`mul([add([1,2]),3],{"k":add([4])})`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !call.Params.HasNested() || len(call.Params.NestedCalls()) != 2 {
+		t.Fatalf("parseFuncStr() = %+v", call.Params)
+	}
+	var made []string
+	params, err := call.Params.Eval(func(c *function.Call) (string, error) {
+		made = append(made, c.Name+c.Params.RawParams[0])
+		return "7", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"[7,3]", `{"k":7}`}; !reflect.DeepEqual(params.RawParams, want) {
+		t.Errorf("Eval() = %v, want %v", params.RawParams, want)
+	}
+	if want := []string{"add[1,2]", "add[4]"}; !reflect.DeepEqual(made, want) {
+		t.Errorf("Eval() made %v, want %v", made, want)
+	}
+}
+
+func TestAgentCallNested(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"mul([add([1,2]),add([3,add([1])])])"}}
+	agent := NewLlmAgent(client)
+	add, err := function.CreateFunction(func(nums []int) int {
+		sum := 0
+		for _, n := range nums {
+			sum += n
+		}
+		return sum
+	}, function.Definition{Name: "add"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mul, err := function.CreateFunction(func(nums []int) int {
+		res := 1
+		for _, n := range nums {
+			res *= n
+		}
+		return res
+	}, function.Definition{Name: "mul"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*function.Function{add, mul} {
+		if err = agent.RegisterFunc(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := agent.Call(context.Background(), "multiply the sum of 1 and 2 by the sum of 3 and 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value() != 12 {
+		t.Errorf("Call() = %v, want 12", res.Value())
+	}
+}
+
+func TestResolveKeywordArgs(t *testing.T) {
+	client := &scriptedClient{outputs: []string{`add(b=2, a=add(a=1,b=3))`}}
+	agent := NewLlmAgent(client)
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := agent.Call(context.Background(), "1 plus 3 plus 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value() != 6 {
+		t.Errorf("Call() = %v, want 6", res.Value())
+	}
+
+	client.outputs = []string{`add(1, c=2)`}
+	if _, err = agent.Call(context.Background(), "1 plus 2"); err == nil || !strings.Contains(err.Error(), "c: unknown argument") {
+		t.Errorf("Call() error = %v", err)
+	}
+}
@@ -3,9 +3,10 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/HFrost0/nlcall"
 	"github.com/HFrost0/nlcall/function"
-	"regexp"
 	"strings"
 )
 
@@ -19,136 +20,534 @@ output by the rules:
 1. output without any explanation.
 2. there is no space between the arguments since you need to save the space.
 3. parameters is null means you should not pass any arguments.
+4. if several independent calls are needed, output one calling string per line.
+5. the result of a call can be an argument by nesting the calling string like mul([add([1,2]),3]).
+6. if none of the functions is suitable, output '''NONE: <reply>''' where <reply> is your reply to the user.
 `
-var funcRex = regexp.MustCompile(`(\w+)\((.*)\)`)
+
+var runSysPromptTemplate = `there are some functions defined below:
+'''
+%s
+'''
+
+Your task is to answer the user with the help of the functions. To call a function, output a formated calling string: '''<func_name>(<arg1>,<arg2>,...)''' and its result will be given to you.
+output by the rules:
+1. output the calling string without any explanation.
+2. there is no space between the arguments since you need to save the space.
+3. parameters is null means you should not pass any arguments.
+4. once the results are enough, answer the user in plain text.
+5. the result of a call can be an argument by nesting the calling string like mul([add([1,2]),3]).
+`
+
+var resultPromptTemplate = `the result of the call is: %s`
+
+var repairPromptTemplate = `your output is invalid: %s
+please output again with the error fixed.`
 
 type Resolver struct {
 	completionClient         CompletionClient
 	completionWithToolClient CompletionWithToolClient
+	streamingClient          StreamingCompletionClient
+	multiChoiceClient        MultiChoiceCompletionClient
 	sysPrompt                string
 	sysPromptTemplate        string
+	runSysPrompt             string
+	runSysPromptTemplate     string
 	fnName2fn                map[string]*function.Function
 	fnNames                  []string
+	outOfScope               map[string]bool // the registered functions left out by ResolveScoped
+	repairAttempts           int
+	extractionHook           func(output string, ext *Extraction)
+	defIndex                 *DefIndex
+	preselectK               int
+	funcChosenHook           func(ctx context.Context, fnName string)
+}
+
+type ResolverOption func(*Resolver)
+
+// WithRepair enables the repair mode, an invalid output of the model like a malformed calling string
+// or arguments rejected by the function is sent back to the model with the error for up to attempts times
+func WithRepair(attempts int) ResolverOption {
+	return func(r *Resolver) {
+		r.repairAttempts = attempts
+	}
+}
+
+// WithExtractionHook sets the hook receiving the calls extracted from each output of the model
+// in prompt mode and the discarded text, which is useful for debugging
+func WithExtractionHook(hook func(output string, ext *Extraction)) ResolverOption {
+	return func(r *Resolver) {
+		r.extractionHook = hook
+	}
+}
+
+// WithFuncChosenHook sets the hook called as soon as the model has chosen a function,
+// which is before the arguments are completed if the client is a StreamingCompletionClient.
+// It is called at most once per completion and ctx is the one of resolving
+func WithFuncChosenHook(hook func(ctx context.Context, fnName string)) ResolverOption {
+	return func(r *Resolver) {
+		r.funcChosenHook = hook
+	}
+}
+
+// preselectInputs is the number of the recent user inputs which the definitions are preselected by
+const preselectInputs = 3
+
+// WithPreselect sends only the k definitions most relevant to the recent user inputs instead of all of them,
+// which are searched in a DefIndex by the embeddings of client
+func WithPreselect(client EmbeddingClient, k int) ResolverOption {
+	return func(r *Resolver) {
+		r.defIndex = NewDefIndex(client)
+		r.preselectK = k
+	}
 }
 
-func NewResolver(completionClient CompletionClient) *Resolver {
+func NewResolver(completionClient CompletionClient, opts ...ResolverOption) *Resolver {
 	r := &Resolver{
-		completionClient:  completionClient,
-		sysPromptTemplate: sysPromptTemplate,
-		fnName2fn:         make(map[string]*function.Function),
+		completionClient:     completionClient,
+		sysPromptTemplate:    sysPromptTemplate,
+		runSysPromptTemplate: runSysPromptTemplate,
+		fnName2fn:            make(map[string]*function.Function),
 	}
 	if v, ok := completionClient.(CompletionWithToolClient); ok {
 		r.completionWithToolClient = v
 	}
+	if v, ok := completionClient.(StreamingCompletionClient); ok {
+		r.streamingClient = v
+	}
+	if v, ok := completionClient.(MultiChoiceCompletionClient); ok {
+		r.multiChoiceClient = v
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
 	return r
 }
 
-func (r *Resolver) Resolve(ctx context.Context, userInput string) (call *function.Call, err error) {
-	if r.completionWithToolClient != nil {
-		return r.resolveByTool(ctx, userInput)
-	}
-	return r.resolveByPrompt(ctx, userInput)
+// invalidOutputErr is returned when the output of the model can't be resolved to a valid call,
+// which can be repaired by the model
+type invalidOutputErr struct {
+	message *MessageContent // the output of the model
+	err     error
 }
 
-// resolveByTool resolves the user input to a function call by trained function calling
-func (r *Resolver) resolveByTool(ctx context.Context, userInput string) (call *function.Call, err error) {
-	messages := []*MessageContent{
-		{Role: "user", Content: userInput},
+func (e *invalidOutputErr) Error() string {
+	return e.err.Error()
+}
+
+func (e *invalidOutputErr) Unwrap() error {
+	return e.err
+}
+
+// invalidOutput returns the invalidOutputErr of the output with err,
+// calling a function out of the scope is not repaired since the model is not offered it
+func invalidOutput(message *MessageContent, err error) error {
+	var notAllowedErr nlcall.FuncNotAllowedErr
+	if errors.As(err, &notAllowedErr) {
+		return notAllowedErr
 	}
-	choices, err := r.completionWithToolClient.CompleteWithTool(ctx, messages, r.GetFuncDefs())
+	return &invalidOutputErr{message: message, err: err}
+}
+
+func (r *Resolver) Resolve(ctx context.Context, userInput string) (call *function.Call, err error) {
+	turn, err := r.resolve(ctx, []*MessageContent{{Role: "user", Content: userInput}}, false)
 	if err != nil {
 		return nil, err
 	}
-	if len(choices) < 1 {
-		return nil, fmt.Errorf("no choices returned")
-	}
-	if len(choices[0].ToolCalls) < 1 {
-		return nil, fmt.Errorf("no calls returned")
+	return turn.Call, nil
+}
+
+// ResolveAll resolves the user input to all the calls of the model,
+// e.g. "weather in Tokyo and Paris" is resolved to two independent calls of weather
+func (r *Resolver) ResolveAll(ctx context.Context, userInput string) (calls []*function.Call, err error) {
+	messages, tools, err := r.prepare(ctx, []*MessageContent{{Role: "user", Content: userInput}}, false)
+	if err != nil {
+		return nil, err
 	}
-	tc := choices[0].ToolCalls[0]
-	fn, ok := r.fnName2fn[tc.Name]
-	if !ok {
-		return nil, fmt.Errorf("function %s not found", tc.Name)
+	err = r.withRepair(messages, func(messages []*MessageContent) error {
+		if r.completionWithToolClient != nil {
+			calls, err = r.resolveAllByTool(ctx, messages, tools)
+		} else {
+			calls, err = r.resolveAllByPrompt(ctx, messages)
+		}
+		return err
+	})
+	return calls, err
+}
+
+// ResolveScoped resolves the user input with only the functions named funcNames,
+// the others are neither described to the model nor accepted in its output, which fails with FuncNotAllowedErr
+func (r *Resolver) ResolveScoped(ctx context.Context, userInput string, funcNames []string) (*function.Call, error) {
+	return r.scoped(funcNames).Resolve(ctx, userInput)
+}
+
+// scoped returns a copy of the resolver with only the functions named funcNames registered
+func (r *Resolver) scoped(funcNames []string) *Resolver {
+	s := *r
+	s.fnName2fn = make(map[string]*function.Function, len(funcNames))
+	s.fnNames = make([]string, 0, len(funcNames))
+	s.outOfScope = make(map[string]bool)
+	for _, name := range r.fnNames {
+		s.outOfScope[name] = true
+		for _, allowed := range funcNames {
+			if name == allowed {
+				s.fnName2fn[name] = r.fnName2fn[name]
+				s.fnNames = append(s.fnNames, name)
+				delete(s.outOfScope, name)
+				break
+			}
+		}
 	}
-	info, err := fn.GetOrGenFuncInfo()
+	s.refreshSysPrompt()
+	return &s
+}
+
+// ResolveSession resolves the user input with the history of the session.
+// The user input and the call are added to the session, then the result of the call should be added by Session.AddResult
+func (r *Resolver) ResolveSession(ctx context.Context, session *Session, userInput string) (*function.Call, error) {
+	msg := &MessageContent{Role: "user", Content: userInput}
+	turn, err := r.resolve(ctx, append(session.Window(), msg), false)
 	if err != nil {
 		return nil, err
 	}
-	rawParams := make(map[string]any)
-	err = json.Unmarshal([]byte(tc.Args), &rawParams)
+	session.Add(msg, turn.Message)
+	return turn.Call, nil
+}
+
+// ResolveTurn resolves the next turn of the conversation, the model either calls a function
+// or answers in plain text once the results of previous calls are enough
+func (r *Resolver) ResolveTurn(ctx context.Context, messages []*MessageContent) (*nlcall.Turn, error) {
+	return r.resolve(ctx, messages, true)
+}
+
+// resolve resolves the conversation in the repair mode, answer reports whether a plain text answer is accepted
+func (r *Resolver) resolve(ctx context.Context, messages []*MessageContent, answer bool) (turn *nlcall.Turn, err error) {
+	messages, tools, err := r.prepare(ctx, messages, answer)
 	if err != nil {
 		return nil, err
 	}
-	call = &function.Call{
-		Name:   tc.Name,
-		Params: new(function.Params),
-	}
-	for _, p := range info.Params {
+	err = r.withRepair(messages, func(messages []*MessageContent) error {
+		if r.completionWithToolClient != nil {
+			turn, err = r.resolveByTool(ctx, messages, tools, answer)
+		} else {
+			turn, err = r.resolveByPrompt(ctx, messages, answer)
+		}
+		return err
+	})
+	return turn, err
+}
 
-		//call.Params.Params = append(call.Params.Params, rawParams[p.Name])
+// prepare returns the messages and the tools sent to the model, tools is nil without tool calling
+func (r *Resolver) prepare(ctx context.Context, messages []*MessageContent, answer bool) ([]*MessageContent, []*Tool, error) {
+	defs, err := r.selectDefs(ctx, messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.completionWithToolClient == nil {
+		return r.promptMessages(messages, r.sysPromptOf(defs, answer)), nil, nil
+	}
+	// the messages of the caller are not modified by repair
+	return append([]*MessageContent(nil), messages...), defs, nil
+}
 
-		// strictly follow the reflection
-		b, err := json.Marshal(rawParams[p.Name])
+// selectDefs returns the definitions sent to the model, which are the ones most relevant to the recent
+// user inputs if preselection is enabled. The earlier inputs give the context of follow-ups like "and Paris",
+// but a function only needed for a nested call may still be left out, so k should leave room for it
+func (r *Resolver) selectDefs(ctx context.Context, messages []*MessageContent) ([]*function.Definition, error) {
+	if r.defIndex == nil {
+		return r.GetFuncDefs(), nil
+	}
+	var inputs []string
+	for i := len(messages) - 1; i >= 0 && len(inputs) < preselectInputs; i-- {
+		if messages[i].Role == "user" {
+			inputs = append([]string{messages[i].Content}, inputs...)
+		}
+	}
+	if len(inputs) > 0 {
+		found, err := r.defIndex.Search(ctx, strings.Join(inputs, "\n"), 0)
 		if err != nil {
 			return nil, err
 		}
-		call.Params.RawParams = append(call.Params.RawParams, string(b))
+		// the index of a scoped resolver has the definitions out of the scope
+		defs := make([]*function.Definition, 0, len(found))
+		for _, def := range found {
+			if _, ok := r.fnName2fn[def.Name]; ok && (r.preselectK <= 0 || len(defs) < r.preselectK) {
+				defs = append(defs, def)
+			}
+		}
+		return defs, nil
+	}
+	return r.GetFuncDefs(), nil
+}
+
+// sysPromptOf returns the system prompt of the definitions,
+// the prompt of all the definitions is only built when a function is added
+func (r *Resolver) sysPromptOf(defs []*function.Definition, answer bool) string {
+	if len(defs) == len(r.fnNames) {
+		if answer {
+			return r.runSysPrompt
+		}
+		return r.sysPrompt
+	}
+	if answer {
+		return fmt.Sprintf(r.runSysPromptTemplate, defsString(defs))
+	}
+	return fmt.Sprintf(r.sysPromptTemplate, defsString(defs))
+}
+
+// withRepair calls resolve until it succeeds, an invalid output is sent back to the model with the error
+// for up to the repair attempts
+func (r *Resolver) withRepair(messages []*MessageContent, resolve func(messages []*MessageContent) error) error {
+	for attempt := 0; ; attempt++ {
+		err := resolve(messages)
+		var invalidErr *invalidOutputErr
+		if !errors.As(err, &invalidErr) {
+			return err
+		}
+		if attempt >= r.repairAttempts {
+			return invalidErr.err
+		}
+		messages = append(messages, invalidErr.message)
+		feedback := fmt.Sprintf(repairPromptTemplate, invalidErr.err)
+		if tcs := invalidErr.message.ToolCalls; len(tcs) > 0 {
+			// every tool call is answered by the error
+			for _, tc := range tcs {
+				messages = append(messages, &MessageContent{Role: "tool", Content: feedback, ToolCallID: tc.ID})
+			}
+		} else {
+			messages = append(messages, &MessageContent{Role: "user", Content: feedback})
+		}
+	}
+}
+
+// promptMessages converts the conversation for models without tool calling,
+// the system prompt is prepended and the results of calls are given by user messages
+func (r *Resolver) promptMessages(messages []*MessageContent, sysPrompt string) []*MessageContent {
+	res := []*MessageContent{{Role: "system", Content: sysPrompt}}
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "tool":
+			msg = &MessageContent{Role: "user", Content: fmt.Sprintf(resultPromptTemplate, msg.Content)}
+		case len(msg.ToolCalls) > 0 && msg.Content == "":
+			msg = &MessageContent{Role: msg.Role, Content: callsString(msg.ToolCalls)}
+		}
+		res = append(res, msg)
 	}
-	return
+	return res
 }
 
-// resolveByPrompt resolves the user input to a function call just by prompt
-func (r *Resolver) resolveByPrompt(ctx context.Context, userInput string) (call *function.Call, err error) {
-	funcStr, err := r.getFuncStr(ctx, userInput)
+// resolveByTool resolves the messages to a function call by trained function calling
+func (r *Resolver) resolveByTool(ctx context.Context, messages []*MessageContent, tools []*Tool, answer bool) (*nlcall.Turn, error) {
+	choice, err := r.complete(ctx, messages, tools)
 	if err != nil {
 		return nil, err
 	}
-	call, err = parseFuncStr(funcStr)
-	return
+	return r.toolTurn(choice, answer)
 }
 
-func (r *Resolver) getFuncStr(ctx context.Context, userInput string) (string, error) {
-	messages := []*MessageContent{
-		{Content: r.sysPrompt, Role: "system"},
-		{Content: userInput, Role: "user"},
+// toolTurn resolves the choice of trained function calling to the turn
+func (r *Resolver) toolTurn(choice *ChoiceContent, answer bool) (*nlcall.Turn, error) {
+	if len(choice.ToolCalls) < 1 {
+		if answer {
+			message := &MessageContent{Role: "assistant", Content: choice.Content}
+			return &nlcall.Turn{Answer: choice.Content, Message: message}, nil
+		}
+		// the model replies in text when none of the tools is suitable
+		return nil, nlcall.NoSuitableFuncErr{Reply: choice.Content}
+	}
+	tcs := choice.ToolCalls
+	if !answer {
+		// a single call is resolved, see ResolveAll for the others
+		tcs = tcs[:1]
+	}
+	message := &MessageContent{Role: "assistant", Content: choice.Content, ToolCalls: tcs}
+	turn := &nlcall.Turn{Message: message}
+	for _, tc := range tcs {
+		call, err := r.bindToolCall(tc)
+		if err != nil {
+			return nil, invalidOutput(message, err)
+		}
+		turn.Calls = append(turn.Calls, call)
+		turn.ToolCallIDs = append(turn.ToolCallIDs, tc.ID)
 	}
-	choices, err := r.completionClient.Complete(ctx, messages)
+	turn.Call, turn.ToolCallID = turn.Calls[0], turn.ToolCallIDs[0]
+	return turn, nil
+}
+
+// resolveAllByTool resolves the messages to all the tool calls of the model
+func (r *Resolver) resolveAllByTool(ctx context.Context, messages []*MessageContent, tools []*Tool) ([]*function.Call, error) {
+	choice, err := r.complete(ctx, messages, tools)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if len(choice.ToolCalls) < 1 {
+		return nil, nlcall.NoSuitableFuncErr{Reply: choice.Content}
 	}
-	if len(choices) < 1 {
-		return "", fmt.Errorf("no choices returned")
+	message := &MessageContent{Role: "assistant", Content: choice.Content, ToolCalls: choice.ToolCalls}
+	calls := make([]*function.Call, len(choice.ToolCalls))
+	for i, tc := range choice.ToolCalls {
+		if calls[i], err = r.bindToolCall(tc); err != nil {
+			return nil, invalidOutput(message, err)
+		}
 	}
-	funcStr := choices[0].Content
-	return funcStr, nil
+	return calls, nil
 }
 
-// parseFuncStr parses a function call string with name and given parameters like "funcName(param1, param2, ...)"
-func parseFuncStr(funcStr string) (call *function.Call, err error) {
-	matches := funcRex.FindStringSubmatch(funcStr)
-	if len(matches) != 3 {
-		return nil, fmt.Errorf("invalid funcStr %s", funcStr)
+// bindToolCall binds the arguments of the tool call to the registered function
+func (r *Resolver) bindToolCall(tc *ToolCall) (*function.Call, error) {
+	fn, err := r.lookup(tc.Name)
+	if err != nil {
+		return nil, err
 	}
-	// Get function name and parameters
-	funcName := matches[1]
-	rawParams := make([]string, 0)
-	paramStr := matches[2]
-	ps := make([]json.RawMessage, 0)
-	s := fmt.Sprintf("[%s]", paramStr)
-	if err = json.Unmarshal([]byte(s), &ps); err != nil {
-		return nil, fmt.Errorf("invalid parameters to parse: %s", paramStr)
+	params, err := fn.BindArgs(tc.Args)
+	if err != nil {
+		return nil, err
 	}
-	for _, p := range ps {
-		rawParams = append(rawParams, string(p))
+	if _, err = fn.GetCallable(params); err != nil {
+		return nil, err
 	}
 	return &function.Call{
-		Name: funcName,
-		Params: &function.Params{
-			RawParams: rawParams,
-		},
+		Name:   tc.Name,
+		Params: params,
 	}, nil
 }
 
+// resolveByPrompt resolves the messages to a function call just by prompt
+func (r *Resolver) resolveByPrompt(ctx context.Context, messages []*MessageContent, answer bool) (*nlcall.Turn, error) {
+	funcStr, err := r.getFuncStr(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return r.promptTurn(funcStr, answer)
+}
+
+// promptTurn resolves the output of the model to the turn by its calling string
+func (r *Resolver) promptTurn(funcStr string, answer bool) (*nlcall.Turn, error) {
+	message := &MessageContent{Role: "assistant", Content: funcStr}
+	if reply, ok := noSuitableReply(funcStr); ok {
+		if answer {
+			return &nlcall.Turn{Answer: reply, Message: message}, nil
+		}
+		return nil, nlcall.NoSuitableFuncErr{Reply: reply}
+	}
+	var callStr string
+	for _, c := range r.extract(funcStr, answer).Calls {
+		// a call quoted in a plain text answer is not made
+		if !answer || !c.Inline {
+			callStr = c.Text
+			break
+		}
+	}
+	if callStr == "" {
+		if answer {
+			return &nlcall.Turn{Answer: stripReasoning(funcStr), Message: message}, nil
+		}
+		return nil, &invalidOutputErr{message: message, err: fmt.Errorf("invalid funcStr %s", funcStr)}
+	}
+	call, err := parseFuncStr(callStr, r.bindExprs)
+	if err == nil {
+		err = r.check(call)
+	}
+	if err != nil {
+		return nil, invalidOutput(message, err)
+	}
+	return &nlcall.Turn{Call: call, Message: message}, nil
+}
+
+// resolveAllByPrompt resolves the messages to all the calls of the output
+func (r *Resolver) resolveAllByPrompt(ctx context.Context, messages []*MessageContent) ([]*function.Call, error) {
+	funcStr, err := r.getFuncStr(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if reply, ok := noSuitableReply(funcStr); ok {
+		return nil, nlcall.NoSuitableFuncErr{Reply: reply}
+	}
+	message := &MessageContent{Role: "assistant", Content: funcStr}
+	var calls []*function.Call
+	for _, c := range r.extract(funcStr, false).Calls {
+		call, err := parseFuncStr(c.Text, r.bindExprs)
+		if err == nil {
+			err = r.check(call)
+		}
+		if err != nil {
+			return nil, invalidOutput(message, err)
+		}
+		calls = append(calls, call)
+	}
+	if len(calls) == 0 {
+		return nil, &invalidOutputErr{message: message, err: fmt.Errorf("invalid funcStr %s", funcStr)}
+	}
+	return calls, nil
+}
+
+// extract extracts the calls of registered functions from the output,
+// calls of unknown functions are extracted if there is no call in resolving so that the error tells the name
+func (r *Resolver) extract(output string, answer bool) *Extraction {
+	ext := Extract(output, func(name string) bool {
+		_, ok := r.fnName2fn[name]
+		return ok
+	})
+	if len(ext.Calls) == 0 && !answer {
+		ext = Extract(output, nil)
+	}
+	if r.extractionHook != nil {
+		r.extractionHook(output, ext)
+	}
+	return ext
+}
+
+// bindExprs binds the keyword arguments of a calling string by the parameter names of the function
+func (r *Resolver) bindExprs(fnName string, args []*function.Expr, names []string) (*function.Params, error) {
+	fn, err := r.lookup(fnName)
+	if err != nil {
+		return nil, err
+	}
+	return fn.BindExprs(args, names)
+}
+
+// lookup returns the registered function of the name, FuncNotAllowedErr if it's out of the scope
+func (r *Resolver) lookup(fnName string) (*function.Function, error) {
+	fn, ok := r.fnName2fn[fnName]
+	if !ok {
+		if r.outOfScope[fnName] {
+			return nil, nlcall.FuncNotAllowedErr{Name: fnName}
+		}
+		return nil, fmt.Errorf("function %s not found", fnName)
+	}
+	return fn, nil
+}
+
+// check checks the call can be made by the registered function,
+// the arguments of a call with nested calls are validated after evaluation
+func (r *Resolver) check(call *function.Call) error {
+	fn, err := r.lookup(call.Name)
+	if err != nil {
+		return err
+	}
+	if !call.Params.HasNested() {
+		_, err = fn.GetCallable(call.Params)
+		return err
+	}
+	if n := len(fn.ParamNames()) - len(fn.GetIgnoreIdx()); call.Params.Len() != n {
+		return fmt.Errorf("parameter count mismatch for function %s", call.Name)
+	}
+	for _, nested := range call.Params.NestedCalls() {
+		if err = r.check(nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) getFuncStr(ctx context.Context, messages []*MessageContent) (string, error) {
+	choice, err := r.complete(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return choice.Content, nil
+}
+
 func (r *Resolver) AddFunc(f *function.Function) bool {
 	// add to store
 	fName := f.GetName()
@@ -157,9 +556,12 @@ func (r *Resolver) AddFunc(f *function.Function) bool {
 	}
 	r.fnName2fn[fName] = f
 	r.fnNames = append(r.fnNames, fName)
+	if r.defIndex != nil {
+		r.defIndex.Add(f.GetDef())
+	}
 
-	// refresh sysPrompt
-	if r.completionWithToolClient != nil {
+	// refresh sysPrompt, which is only used without tool calling
+	if r.completionWithToolClient == nil {
 		r.refreshSysPrompt()
 	}
 	return true
@@ -174,12 +576,18 @@ func (r *Resolver) GetFuncDefs() []*function.Definition {
 }
 
 func (r *Resolver) refreshSysPrompt() {
-	funcDefs := make([]string, len(r.fnNames), len(r.fnNames))
-	for idx, k := range r.fnNames {
-		d, _ := json.Marshal(r.fnName2fn[k])
-		funcDefs[idx] = string(d)
-	}
-	allDefStr := strings.Join(funcDefs, "\n")
+	allDefStr := defsString(r.GetFuncDefs())
 	funcPrompt := fmt.Sprintf(r.sysPromptTemplate, allDefStr)
 	r.sysPrompt = funcPrompt
+	r.runSysPrompt = fmt.Sprintf(r.runSysPromptTemplate, allDefStr)
+}
+
+// defsString returns the definitions in json, one per line
+func defsString(defs []*function.Definition) string {
+	funcDefs := make([]string, len(defs))
+	for idx, def := range defs {
+		d, _ := json.Marshal(def)
+		funcDefs[idx] = string(d)
+	}
+	return strings.Join(funcDefs, "\n")
 }
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HFrost0/nlcall"
+	"github.com/HFrost0/nlcall/function"
+)
+
+// scriptedClient replies the outputs in order and records the received messages
+type scriptedClient struct {
+	outputs  []string
+	messages [][]*MessageContent
+}
+
+func (c *scriptedClient) Complete(ctx context.Context, messages []*MessageContent) ([]*ChoiceContent, error) {
+	c.messages = append(c.messages, messages)
+	output := c.outputs[0]
+	c.outputs = c.outputs[1:]
+	return []*ChoiceContent{{Content: output}}, nil
+}
+
+func newAddFunction(t *testing.T) *function.Function {
+	f, err := function.CreateFunction(func(a, b int) int { return a + b }, function.Definition{Name: "add"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestSysPromptDefs(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"add(1,2)"}}
+	r := NewResolver(client)
+	f := newAddFunction(t)
+	r.AddFunc(f)
+	if _, err := r.Resolve(context.Background(), "1 plus 2"); err != nil {
+		t.Fatal(err)
+	}
+	// the prompt lists the definitions of the functions added without tool calling
+	def, err := json.Marshal(f.GetDef())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sysPrompt := client.messages[0][0].Content; !strings.Contains(sysPrompt, string(def)) {
+		t.Errorf("system prompt = %s, want the definition %s", sysPrompt, def)
+	}
+}
+
+func TestResolveRepair(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"add 1 2", `add(1,"2")`, "add(1,2)"}}
+	r := NewResolver(client, WithRepair(2))
+	r.AddFunc(newAddFunction(t))
+	call, err := r.Resolve(context.Background(), "1 plus 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Name != "add" || strings.Join(call.Params.RawParams, ",") != "1,2" {
+		t.Errorf("Resolve() = %s %v", call.Name, call.Params.RawParams)
+	}
+	// system, user, then the bad output and the error of each attempt
+	last := client.messages[2]
+	if len(last) != 6 || last[4].Role != "assistant" || last[4].Content != `add(1,"2")` {
+		t.Fatalf("messages of the last attempt = %v", last)
+	}
+	if !strings.Contains(last[5].Content, "expected integer, got string") {
+		t.Errorf("repair message = %s", last[5].Content)
+	}
+	if !strings.Contains(last[0].Content, `"name":"add"`) {
+		t.Errorf("system prompt = %s", last[0].Content)
+	}
+
+	// without repair the error is returned
+	client = &scriptedClient{outputs: []string{"add 1 2"}}
+	r = NewResolver(client)
+	r.AddFunc(newAddFunction(t))
+	if _, err = r.Resolve(context.Background(), "1 plus 2"); err == nil || !strings.HasPrefix(err.Error(), "invalid funcStr") {
+		t.Errorf("Resolve() error = %v", err)
+	}
+}
+
+func TestAgentRun(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"add(1,2)", "add(3,4)", "1+2=3 and 3+4=7"}}
+	agent := NewLlmAgent(client)
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := agent.Run(context.Background(), "what are 1+2 and 3+4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Answer != "1+2=3 and 3+4=7" || len(res.Steps) != 2 || res.Steps[1].Result.Value() != 7 {
+		t.Errorf("Run() = %+v", res)
+	}
+	var roles []string
+	for _, msg := range res.Messages {
+		roles = append(roles, msg.Role)
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,tool,assistant,tool,assistant" {
+		t.Errorf("Run() messages = %s", got)
+	}
+	// results are given by user messages without tool calling
+	if got := client.messages[2][3]; got.Role != "user" || got.Content != "the result of the call is: 3" {
+		t.Errorf("result message = %+v", got)
+	}
+
+	client = &scriptedClient{outputs: []string{"add(1,2)", "add(1,2)"}}
+	agent = NewLlmAgent(client)
+	if err = agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err = agent.Run(context.Background(), "1+2", nlcall.WithMaxSteps(1))
+	if !errors.Is(err, nlcall.MaxStepsErr) || len(res.Steps) != 1 {
+		t.Errorf("Run() = %+v, %v", res, err)
+	}
+}
+
+func TestResolveNoSuitableFunc(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"<think>no function fits</think>'''NONE: I can only add numbers.'''"}}
+	agent := NewLlmAgent(client, WithRepair(1))
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := agent.Call(context.Background(), "tell me a joke")
+	var noSuitableErr nlcall.NoSuitableFuncErr
+	if !errors.As(err, &noSuitableErr) || noSuitableErr.Reply != "I can only add numbers." {
+		t.Errorf("Call() error = %v", err)
+	}
+	// the rule is described in the prompt
+	if !strings.Contains(client.messages[0][0].Content, "NONE:") {
+		t.Errorf("system prompt = %s", client.messages[0][0].Content)
+	}
+
+	toolAgent := NewLlmAgent(&toolClient{content: "Why did the chicken cross the road?"})
+	if err = toolAgent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = toolAgent.Call(context.Background(), "tell me a joke")
+	if !errors.As(err, &noSuitableErr) || noSuitableErr.Reply != "Why did the chicken cross the road?" {
+		t.Errorf("Call() error = %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HFrost0/nlcall"
+	"github.com/HFrost0/nlcall/function"
+)
+
+// unscopedResolver hides ResolveScoped of the resolver
+type unscopedResolver struct {
+	r *Resolver
+}
+
+func (u *unscopedResolver) AddFunc(f *function.Function) bool {
+	return u.r.AddFunc(f)
+}
+
+func (u *unscopedResolver) Resolve(ctx context.Context, userInput string) (*function.Call, error) {
+	return u.r.Resolve(ctx, userInput)
+}
+
+func registerScopedFuncs(t *testing.T, agent *nlcall.Agent) {
+	ctx := context.Background()
+	if _, err := agent.RegisterFn(ctx, func(a, b int) int { return a + b },
+		nlcall.WithName("add"), nlcall.WithDescription("add two numbers"), nlcall.WithToolsets("math")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agent.RegisterFn(ctx, func(city string) string { return city + " is sunny" },
+		nlcall.WithName("weather"), nlcall.WithDescription("the weather of the city"), nlcall.WithToolsets("travel")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAgentCallScoped(t *testing.T) {
+	client := &scriptedClient{outputs: []string{`weather("Paris")`}}
+	agent := NewLlmAgent(client)
+	registerScopedFuncs(t, agent)
+
+	res, err := agent.Call(context.Background(), "weather in Paris", nlcall.WithAllowedToolsets("travel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value() != "Paris is sunny" {
+		t.Errorf("Call() = %v", res.Value())
+	}
+	if sysPrompt := client.messages[0][0].Content; !strings.Contains(sysPrompt, `"name":"weather"`) || strings.Contains(sysPrompt, `"name":"add"`) {
+		t.Errorf("system prompt = %s", sysPrompt)
+	}
+
+	// the function out of the scope is not accepted nor repaired
+	client.outputs = []string{`weather("Paris")`, `add(1,2)`}
+	repairAgent := NewLlmAgent(client, WithRepair(1))
+	registerScopedFuncs(t, repairAgent)
+	_, err = repairAgent.AssignCallable(context.Background(), "weather in Paris", nlcall.WithAllowedFuncs("add"))
+	var notAllowedErr nlcall.FuncNotAllowedErr
+	if !errors.As(err, &notAllowedErr) || notAllowedErr.Name != "weather" || len(client.outputs) != 1 {
+		t.Errorf("AssignCallable() error = %v", err)
+	}
+
+	if _, err = agent.AssignCallable(context.Background(), "weather in Paris", nlcall.WithAllowedFuncs("wether")); err == nil ||
+		!strings.Contains(err.Error(), "wether is not registered") {
+		t.Errorf("AssignCallable() with an unregistered function error = %v", err)
+	}
+
+	if _, _, err = agent.AssignFunc(context.Background(), "weather in Paris", nlcall.WithAllowedToolsets("billing")); err == nil ||
+		!strings.Contains(err.Error(), "billing does not exist") {
+		t.Errorf("AssignFunc() with an unknown toolset error = %v", err)
+	}
+
+	// an empty allowlist allows nothing rather than everything
+	var granted []string
+	pending := len(client.outputs)
+	if _, err = agent.Call(context.Background(), "weather in Paris", nlcall.WithAllowedToolsets(granted...)); err == nil ||
+		!strings.Contains(err.Error(), "no registered functions are allowed") || len(client.outputs) != pending {
+		t.Errorf("Call() with an empty scope error = %v", err)
+	}
+}
+
+func TestAgentCallScopedUnscopedResolver(t *testing.T) {
+	client := &scriptedClient{outputs: []string{`add(1,2)`, `add(1,2)`}}
+	agent := nlcall.NewAgent(&unscopedResolver{r: NewResolver(client)}, nil)
+	registerScopedFuncs(t, agent)
+
+	// the resolved name is checked if the resolver can't be scoped
+	_, err := agent.Call(context.Background(), "1 plus 2", nlcall.WithAllowedToolsets("travel"))
+	var notAllowedErr nlcall.FuncNotAllowedErr
+	if !errors.As(err, &notAllowedErr) || notAllowedErr.Name != "add" {
+		t.Errorf("Call() error = %v", err)
+	}
+	res, err := agent.Call(context.Background(), "1 plus 2", nlcall.WithAllowedToolsets("travel"), nlcall.WithAllowedFuncs("add"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value() != 3 {
+		t.Errorf("Call() = %v, want 3", res.Value())
+	}
+}
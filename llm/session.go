@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Session holds the conversation history so that follow-ups like "now do the same for Paris"
+// can be resolved with the earlier turns, see Resolver.ResolveSession
+type Session struct {
+	mu       sync.Mutex
+	messages []*MessageContent
+	window   int // the max number of messages sent to the model, 0 means no limit
+}
+
+type SessionOption func(*Session)
+
+// WithWindow limits the history sent to the model to the last n messages
+func WithWindow(n int) SessionOption {
+	return func(s *Session) {
+		s.window = n
+	}
+}
+
+func NewSession(opts ...SessionOption) *Session {
+	s := &Session{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add appends messages to the history
+func (s *Session) Add(messages ...*MessageContent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, messages...)
+}
+
+// AddResult answers the next unanswered call of the last calls in the history with its result,
+// it should be called once for each of the calls in order
+func (s *Session) AddResult(result string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := &MessageContent{Role: "tool", Content: result}
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if tcs := s.messages[i].ToolCalls; len(tcs) > 0 {
+			answered := answeredIDs(s.messages[i+1:])
+			for _, tc := range tcs {
+				if !answered[tc.ID] {
+					msg.ToolCallID = tc.ID
+					break
+				}
+			}
+			break
+		}
+	}
+	s.messages = append(s.messages, msg)
+}
+
+// answeredIDs returns the ids of the calls answered by the tool messages at the beginning of messages
+func answeredIDs(messages []*MessageContent) map[string]bool {
+	ids := make(map[string]bool)
+	for _, msg := range messages {
+		if msg.Role != "tool" {
+			break
+		}
+		ids[msg.ToolCallID] = true
+	}
+	return ids
+}
+
+// Messages returns the whole history
+func (s *Session) Messages() []*MessageContent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*MessageContent(nil), s.messages...)
+}
+
+// Window returns the messages sent to the model, which are the last messages within the window
+// starting from a user message. Calls of which any has no result are given as calling strings,
+// and the results they have are given as user messages.
+func (s *Session) Window() []*MessageContent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := 0
+	if s.window > 0 && len(s.messages) > s.window {
+		start = len(s.messages) - s.window
+	}
+	// a tool message must follow its call
+	for start < len(s.messages) && s.messages[start].Role != "user" {
+		start++
+	}
+	res := make([]*MessageContent, 0, len(s.messages)-start)
+	for i := start; i < len(s.messages); i++ {
+		msg := s.messages[i]
+		if len(msg.ToolCalls) > 0 && !allAnswered(msg.ToolCalls, s.messages[i+1:]) {
+			res = append(res, &MessageContent{Role: msg.Role, Content: callsString(msg.ToolCalls)})
+			// a tool message must answer a call
+			for ; i+1 < len(s.messages) && s.messages[i+1].Role == "tool"; i++ {
+				res = append(res, &MessageContent{Role: "user", Content: fmt.Sprintf(resultPromptTemplate, s.messages[i+1].Content)})
+			}
+			continue
+		}
+		res = append(res, msg)
+	}
+	return res
+}
+
+// allAnswered reports whether every call is answered by the tool messages following it
+func allAnswered(tcs []*ToolCall, following []*MessageContent) bool {
+	answered := answeredIDs(following)
+	for _, tc := range tcs {
+		if !answered[tc.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// callsString returns the calling strings of the tool calls, one per line
+func callsString(tcs []*ToolCall) string {
+	strs := make([]string, len(tcs))
+	for i, tc := range tcs {
+		strs[i] = fmt.Sprintf("%s(%s)", tc.Name, tc.Args)
+	}
+	return strings.Join(strs, "\n")
+}
+
+// Reset clears the history
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
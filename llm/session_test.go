@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/HFrost0/nlcall"
+)
+
+func TestSessionWindow(t *testing.T) {
+	s := NewSession(WithWindow(4))
+	s.Add(&MessageContent{Role: "user", Content: "weather in Beijing"},
+		&MessageContent{Role: "assistant", ToolCalls: []*ToolCall{{ID: "1", Name: "weather", Args: `{"city":"Beijing"}`}}})
+	s.AddResult("sunny")
+	s.Add(&MessageContent{Role: "user", Content: "and Paris"},
+		&MessageContent{Role: "assistant", ToolCalls: []*ToolCall{{ID: "2", Name: "weather", Args: `{"city":"Paris"}`}}})
+
+	if got := s.Messages()[2]; got.Role != "tool" || got.ToolCallID != "1" {
+		t.Errorf("AddResult() = %+v", got)
+	}
+	// the window starts from a user message and the last call has no result
+	w := s.Window()
+	if len(w) != 2 || w[0].Content != "and Paris" || w[1].Content != `weather({"city":"Paris"})` || w[1].ToolCalls != nil {
+		t.Errorf("Window() = %+v", w)
+	}
+	s.Reset()
+	if len(s.Window()) != 0 {
+		t.Errorf("Reset() = %v", s.Messages())
+	}
+}
+
+func TestSessionParallelCalls(t *testing.T) {
+	s := NewSession()
+	s.Add(&MessageContent{Role: "user", Content: "weather in Tokyo and Paris"},
+		&MessageContent{Role: "assistant", ToolCalls: []*ToolCall{
+			{ID: "1", Name: "weather", Args: `{"city":"Tokyo"}`},
+			{ID: "2", Name: "weather", Args: `{"city":"Paris"}`},
+		}})
+	s.AddResult("rainy")
+
+	// the calls are given as calling strings until all of them are answered
+	w := s.Window()
+	if len(w) != 3 || w[1].Content != "weather({\"city\":\"Tokyo\"})\nweather({\"city\":\"Paris\"})" ||
+		w[2].Role != "user" || w[2].Content != "the result of the call is: rainy" {
+		t.Errorf("Window() = %+v", w)
+	}
+
+	// every call is answered by its own result
+	s.AddResult("sunny")
+	msgs := s.Messages()
+	if msgs[2].ToolCallID != "1" || msgs[3].ToolCallID != "2" {
+		t.Errorf("AddResult() ids = %s, %s, want 1, 2", msgs[2].ToolCallID, msgs[3].ToolCallID)
+	}
+	if w = s.Window(); len(w) != 4 || len(w[1].ToolCalls) != 2 {
+		t.Errorf("Window() = %+v", w)
+	}
+}
+
+func TestResolveSession(t *testing.T) {
+	client := &scriptedClient{outputs: []string{"add(1,2)", "add(3,2)"}}
+	r := NewResolver(client)
+	r.AddFunc(newAddFunction(t))
+	s := NewSession()
+	if _, err := r.ResolveSession(context.Background(), s, "1 plus 2"); err != nil {
+		t.Fatal(err)
+	}
+	s.AddResult("3")
+	call, err := r.ResolveSession(context.Background(), s, "now 3 instead of 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(call.Params.RawParams, ",") != "3,2" {
+		t.Errorf("ResolveSession() = %v", call.Params.RawParams)
+	}
+	// system, the earlier turn with its result and the user input
+	var contents []string
+	for _, msg := range client.messages[1] {
+		contents = append(contents, msg.Content)
+	}
+	want := "1 plus 2|add(1,2)|the result of the call is: 3|now 3 instead of 1"
+	if got := strings.Join(contents[1:], "|"); got != want {
+		t.Errorf("messages = %s, want %s", got, want)
+	}
+	if len(s.Messages()) != 5 {
+		t.Errorf("Messages() = %v", s.Messages())
+	}
+
+	// Agent.Run continues the session
+	client.outputs = []string{"add(3,3)", "6"}
+	agent := NewLlmAgent(client)
+	if err = agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := agent.Run(context.Background(), "and 3 plus 3?", nlcall.WithHistory(s.Window()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.messages[2]) != 7 || len(res.Messages) != 4 {
+		t.Errorf("Run() messages = %d, want the history and the user input", len(client.messages[2]))
+	}
+	s.Add(res.Messages...)
+	if len(s.Messages()) != 9 {
+		t.Errorf("Messages() = %v", s.Messages())
+	}
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// choiceAccumulator accumulates the chunks of a streamed choice
+type choiceAccumulator struct {
+	content   strings.Builder
+	toolCalls []*ToolCall
+	indexes   []int // the stream index of each tool call
+}
+
+// add merges the chunk, the argument fragments are appended to the tool call of the same index.
+// A fragment with another ID starts a new call since some servers reuse the index
+func (a *choiceAccumulator) add(chunk *ChunkContent) {
+	a.content.WriteString(chunk.Content)
+	for _, d := range chunk.ToolCallDeltas {
+		var tc *ToolCall
+		for i := len(a.toolCalls) - 1; i >= 0; i-- {
+			if a.indexes[i] == d.Index {
+				tc = a.toolCalls[i]
+				break
+			}
+		}
+		if tc == nil || (d.ID != "" && tc.ID != "" && d.ID != tc.ID) {
+			tc = &ToolCall{}
+			a.toolCalls = append(a.toolCalls, tc)
+			a.indexes = append(a.indexes, d.Index)
+		}
+		if tc.ID == "" {
+			tc.ID = d.ID
+		}
+		tc.Name += d.Name
+		tc.Args += d.Args
+	}
+}
+
+func (a *choiceAccumulator) choice() *ChoiceContent {
+	return &ChoiceContent{Content: a.content.String(), ToolCalls: a.toolCalls}
+}
+
+// chosenFunc returns the name of the function chosen by the model so far or "" if it is not decided yet.
+// The name of a tool call is decided once its arguments begin, and a calling string is decided once
+// the name of a registered function followed by "(" begins a line out of reasoning blocks
+func (r *Resolver) chosenFunc(a *choiceAccumulator) string {
+	if len(a.toolCalls) > 0 {
+		tc := a.toolCalls[0]
+		if _, ok := r.fnName2fn[tc.Name]; ok && (tc.Args != "" || len(a.toolCalls) > 1) {
+			return tc.Name
+		}
+		return ""
+	}
+	text := new(Extraction).stripReasoning(a.content.String())
+	for _, loc := range callNameRex.FindAllStringIndex(text, -1) {
+		lineStart := strings.LastIndexByte(text[:loc[0]], '\n') + 1
+		if strings.Trim(text[lineStart:loc[0]], "`' \t\r") != "" {
+			continue
+		}
+		if _, ok := r.fnName2fn[text[loc[0]:loc[1]-1]]; ok {
+			return text[loc[0] : loc[1]-1]
+		}
+	}
+	return ""
+}
+
+// complete completes the messages with tools or without them if tools is nil,
+// the choice is streamed only for the hook of WithFuncChosenHook if the client supports streaming
+// so that the chosen function is surfaced early, the streaming request is not retried by the client
+func (r *Resolver) complete(ctx context.Context, messages []*MessageContent, tools []*Tool) (*ChoiceContent, error) {
+	if r.streamingClient == nil || r.funcChosenHook == nil {
+		var choices []*ChoiceContent
+		var err error
+		if tools != nil {
+			choices, err = r.completionWithToolClient.CompleteWithTool(ctx, messages, tools)
+		} else {
+			choices, err = r.completionClient.Complete(ctx, messages)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(choices) < 1 {
+			return nil, fmt.Errorf("no choices returned")
+		}
+		return choices[0], nil
+	}
+	acc := &choiceAccumulator{}
+	chosen := false
+	err := r.streamingClient.CompleteStream(ctx, messages, tools, func(chunk *ChunkContent) error {
+		acc.add(chunk)
+		if chosen {
+			return nil
+		}
+		if name := r.chosenFunc(acc); name != "" {
+			chosen = true
+			r.funcChosenHook(ctx, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc.choice(), nil
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// streamingClient streams the chunks of a tool calling choice and records how many chunks are sent
+type streamingClient struct {
+	chunks []*ChunkContent
+	sent   int
+}
+
+func (c *streamingClient) Complete(ctx context.Context, messages []*MessageContent) ([]*ChoiceContent, error) {
+	panic("streaming is expected")
+}
+
+func (c *streamingClient) CompleteWithTool(ctx context.Context, messages []*MessageContent, tools []*Tool) ([]*ChoiceContent, error) {
+	panic("streaming is expected")
+}
+
+func (c *streamingClient) CompleteStream(ctx context.Context, messages []*MessageContent, tools []*Tool, onChunk func(chunk *ChunkContent) error) error {
+	for _, chunk := range c.chunks {
+		c.sent++
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promptStreamingClient streams the content without tool calling
+type promptStreamingClient struct {
+	contents []string
+	sent     int
+}
+
+func (c *promptStreamingClient) Complete(ctx context.Context, messages []*MessageContent) ([]*ChoiceContent, error) {
+	panic("streaming is expected")
+}
+
+func (c *promptStreamingClient) CompleteStream(ctx context.Context, messages []*MessageContent, tools []*Tool, onChunk func(chunk *ChunkContent) error) error {
+	for _, content := range c.contents {
+		c.sent++
+		if err := onChunk(&ChunkContent{Content: content}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestChoiceAccumulator(t *testing.T) {
+	acc := &choiceAccumulator{}
+	for _, chunk := range []*ChunkContent{
+		{Content: "sure"},
+		{ToolCallDeltas: []*ToolCallDelta{{Index: 0, ID: "a", Name: "add"}}},
+		{ToolCallDeltas: []*ToolCallDelta{{Index: 0, Args: `{"a":`}, {Index: 1, ID: "b", Name: "add", Args: `{"a":3,`}}},
+		{ToolCallDeltas: []*ToolCallDelta{{Index: 0, Args: `1,"b":2}`}, {Index: 1, Args: `"b":4}`}}},
+		// the index is reused with another ID
+		{ToolCallDeltas: []*ToolCallDelta{{Index: 0, ID: "c", Name: "add", Args: `{}`}}},
+	} {
+		acc.add(chunk)
+	}
+	choice := acc.choice()
+	var got []string
+	for _, tc := range choice.ToolCalls {
+		got = append(got, tc.ID+" "+tc.Name+tc.Args)
+	}
+	want := `a add{"a":1,"b":2};b add{"a":3,"b":4};c add{}`
+	if choice.Content != "sure" || strings.Join(got, ";") != want {
+		t.Errorf("choice() = %q %q, want %q", choice.Content, strings.Join(got, ";"), want)
+	}
+}
+
+func TestResolveStream(t *testing.T) {
+	client := &streamingClient{chunks: []*ChunkContent{
+		{ToolCallDeltas: []*ToolCallDelta{{ID: "call_1", Name: "add"}}},
+		{ToolCallDeltas: []*ToolCallDelta{{Args: `{"a":`}}},
+		{ToolCallDeltas: []*ToolCallDelta{{Args: `1,"b"`}}},
+		{ToolCallDeltas: []*ToolCallDelta{{Args: `:2}`}}},
+	}}
+	var chosen []string
+	sentAtChosen := 0
+	agent := NewLlmAgent(client, WithFuncChosenHook(func(ctx context.Context, fnName string) {
+		chosen = append(chosen, fnName)
+		sentAtChosen = client.sent
+	}))
+	if err := agent.RegisterFunc(newAddFunction(t)); err != nil {
+		t.Fatal(err)
+	}
+	res, err := agent.Call(context.Background(), "1 plus 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value() != 3 {
+		t.Errorf("Call() = %v, want 3", res.Value())
+	}
+	if len(chosen) != 1 || chosen[0] != "add" || sentAtChosen != 2 {
+		t.Errorf("chosen = %v after %d chunks", chosen, sentAtChosen)
+	}
+}
+
+func TestResolveStreamByPrompt(t *testing.T) {
+	client := &promptStreamingClient{contents: []string{"<think>maybe add(", "1,2)?</think>\n", "Here:\n```\nad", "d(1,", "2)\n```"}}
+	var chosen []string
+	sentAtChosen := 0
+	r := NewResolver(client, WithFuncChosenHook(func(ctx context.Context, fnName string) {
+		chosen = append(chosen, fnName)
+		sentAtChosen = client.sent
+	}))
+	r.AddFunc(newAddFunction(t))
+	call, err := r.Resolve(context.Background(), "1 plus 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Name != "add" || strings.Join(call.Params.RawParams, ",") != "1,2" {
+		t.Errorf("Resolve() = %s %v", call.Name, call.Params.RawParams)
+	}
+	// the call in the reasoning block is not the chosen one
+	if len(chosen) != 1 || chosen[0] != "add" || sentAtChosen != 4 {
+		t.Errorf("chosen = %v after %d chunks", chosen, sentAtChosen)
+	}
+}
+
+// noStreamClient is a streaming client expected to complete without streaming
+type noStreamClient struct {
+	scriptedClient
+}
+
+func (c *noStreamClient) CompleteStream(ctx context.Context, messages []*MessageContent, tools []*Tool, onChunk func(chunk *ChunkContent) error) error {
+	return errors.New("streaming is not expected")
+}
+
+func TestResolveWithoutStream(t *testing.T) {
+	// the blocking completion, which is retried by the client, is used without the hook
+	client := &noStreamClient{scriptedClient{outputs: []string{"add(1,2)"}}}
+	r := NewResolver(client)
+	r.AddFunc(newAddFunction(t))
+	call, err := r.Resolve(context.Background(), "1 plus 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.Name != "add" || len(client.messages) != 1 {
+		t.Errorf("Resolve() = %s after %d completions", call.Name, len(client.messages))
+	}
+}
@@ -2,8 +2,10 @@ package llm
 
 import "github.com/HFrost0/nlcall"
 
-func NewLlmAgent(client CompletionClient) *nlcall.Agent {
-	resolver := NewResolver(client)
+func NewLlmAgent(client CompletionClient, opts ...ResolverOption) *nlcall.Agent {
+	resolver := NewResolver(client, opts...)
 	definer := NewDefiner(client)
 	return nlcall.NewAgent(resolver, definer)
 }
+
+var _ nlcall.InfoDefiner = (*Definer)(nil)
@@ -0,0 +1,31 @@
+package nlcall
+
+import "github.com/HFrost0/nlcall/function"
+
+// Message is a message of the conversation with the model
+type Message struct {
+	Role       string // system, user, assistant or tool
+	Content    string
+	ToolCalls  []*ToolCall // the calls made by an assistant message
+	ToolCallID string      // the id of the call that a tool message answers
+}
+
+// ToolCall is a function call made by the model
+type ToolCall struct {
+	ID   string
+	Name string
+	Args string // should be a json string
+}
+
+// Turn is the next step of a conversation resolved by TurnResolver,
+// either the calls to be made or the final answer
+type Turn struct {
+	Call       *function.Call // nil for the final answer, the first call if the model made several
+	ToolCallID string         // the id of the call, which is answered by the tool message of its result
+	// all the calls made at once in order, which are independent of each other, and their ids.
+	// Only Call is made if they are not given
+	Calls       []*function.Call
+	ToolCallIDs []string
+	Answer      string
+	Message     *Message // the assistant message to be appended to the history
+}
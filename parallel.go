@@ -0,0 +1,57 @@
+package nlcall
+
+import (
+	"context"
+	"github.com/HFrost0/nlcall/function"
+	"sync"
+)
+
+// CallAll resolves the user input to several independent calls and invokes them by InvokeAll.
+// The user input is resolved to a single call if the resolver doesn't implement MultiResolver
+func (a *Agent) CallAll(ctx context.Context, userInput string, workers int) ([]*Step, error) {
+	if userInput == "" {
+		return nil, EmptyUserInputErr
+	}
+	var calls []*function.Call
+	if multiResolver, ok := a.resolver.(MultiResolver); ok {
+		var err error
+		if calls, err = multiResolver.ResolveAll(ctx, userInput); err != nil {
+			return nil, err
+		}
+	} else {
+		call, err := a.resolver.Resolve(ctx, userInput)
+		if err != nil {
+			return nil, err
+		}
+		calls = []*function.Call{call}
+	}
+	return a.InvokeAll(ctx, calls, workers), nil
+}
+
+// InvokeAll invokes the calls concurrently by at most workers goroutines, workers <= 0 means no limit.
+// The steps are in the order of the calls and each of them has its own result or error
+func (a *Agent) InvokeAll(ctx context.Context, calls []*function.Call, workers int) []*Step {
+	if workers <= 0 || workers > len(calls) {
+		workers = len(calls)
+	}
+	steps := make([]*Step, len(calls))
+	idx := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				step := &Step{Call: calls[i]}
+				step.Result, step.Err = a.Invoke(ctx, calls[i])
+				steps[i] = step
+			}
+		}()
+	}
+	for i := range calls {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+	return steps
+}
@@ -0,0 +1,101 @@
+package nlcall
+
+import (
+	"context"
+	"fmt"
+	"github.com/HFrost0/nlcall/function"
+)
+
+const defaultMaxSteps = 10
+
+// Step is a call made by Agent.Run or Agent.InvokeAll
+type Step struct {
+	Call   *function.Call
+	Result *function.Result // nil if the call failed
+	Err    error            // the error of the call, which is fed back to the model
+}
+
+// RunResult is the result of Agent.Run
+type RunResult struct {
+	Answer   string     // the final answer of the model
+	Steps    []*Step    // the calls in order
+	Messages []*Message // the messages of this run starting from the user input, the history is excluded
+}
+
+type RunOpts struct {
+	MaxSteps int        // the maximum number of calls, 10 by default
+	History  []*Message // the earlier messages of the conversation
+}
+
+type RunOption func(*RunOpts)
+
+// WithHistory continues the conversation of the earlier messages, e.g. the window of a session
+func WithHistory(messages ...*Message) RunOption {
+	return func(o *RunOpts) {
+		o.History = messages
+	}
+}
+
+func WithMaxSteps(maxSteps int) RunOption {
+	return func(o *RunOpts) {
+		o.MaxSteps = maxSteps
+	}
+}
+
+// Run resolves and calls functions in a loop until the model gives the final answer.
+// The result of each call, or its error, is appended to the conversation as a tool message.
+// MaxStepsErr is returned along with the partial RunResult if the model keeps calling functions.
+// The resolver of the agent should implement TurnResolver
+func (a *Agent) Run(ctx context.Context, userInput string, opts ...RunOption) (*RunResult, error) {
+	if userInput == "" {
+		return nil, EmptyUserInputErr
+	}
+	turnResolver, ok := a.resolver.(TurnResolver)
+	if !ok {
+		return nil, fmt.Errorf("resolver %T does not support multi-turn", a.resolver)
+	}
+	runOpts := &RunOpts{MaxSteps: defaultMaxSteps}
+	for _, opt := range opts {
+		opt(runOpts)
+	}
+	res := &RunResult{
+		Messages: []*Message{{Role: "user", Content: userInput}},
+	}
+	// full slice expression so that appending never modifies the history of the caller
+	history := runOpts.History[:len(runOpts.History):len(runOpts.History)]
+	for {
+		turn, err := turnResolver.ResolveTurn(ctx, append(history, res.Messages...))
+		if err != nil {
+			return res, err
+		}
+		if turn.Call == nil {
+			res.Messages = append(res.Messages, turn.Message)
+			res.Answer = turn.Answer
+			return res, nil
+		}
+		calls, ids := turn.Calls, turn.ToolCallIDs
+		if len(calls) == 0 {
+			calls, ids = []*function.Call{turn.Call}, []string{turn.ToolCallID}
+		}
+		// the conversation ends with the last answered calls
+		if len(res.Steps)+len(calls) > runOpts.MaxSteps {
+			return res, MaxStepsErr
+		}
+		res.Messages = append(res.Messages, turn.Message)
+		// the calls made at once are independent and invoked concurrently
+		steps := a.InvokeAll(ctx, calls, 0)
+		res.Steps = append(res.Steps, steps...)
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		for i, step := range steps {
+			var content string
+			if step.Err != nil {
+				content = "error: " + step.Err.Error()
+			} else {
+				content = step.Result.String()
+			}
+			res.Messages = append(res.Messages, &Message{Role: "tool", Content: content, ToolCallID: ids[i]})
+		}
+	}
+}
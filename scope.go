@@ -0,0 +1,124 @@
+package nlcall
+
+import (
+	"context"
+	"fmt"
+	"github.com/HFrost0/nlcall/function"
+)
+
+type CallOption func(*CallOpts)
+
+// CallOpts restricts the functions that the user input can be resolved to,
+// the functions in any of the toolsets and the ones in the allowlist are allowed.
+// All the functions are allowed if neither option is given, even an empty one restricts the call
+type CallOpts struct {
+	Toolsets []string // the toolsets given by WithToolsets when registering
+	Funcs    []string // the allowlist of function names
+	scoped   bool     // whether any of the options is given
+}
+
+func WithAllowedToolsets(toolsets ...string) CallOption {
+	return func(o *CallOpts) {
+		o.Toolsets = append(o.Toolsets, toolsets...)
+		o.scoped = true
+	}
+}
+
+func WithAllowedFuncs(funcNames ...string) CallOption {
+	return func(o *CallOpts) {
+		o.Funcs = append(o.Funcs, funcNames...)
+		o.scoped = true
+	}
+}
+
+// addToToolsets adds the registered function to the toolsets
+func (a *Agent) addToToolsets(funcName string, toolsets []string) {
+	for _, toolset := range toolsets {
+		a.toolsets[toolset] = append(a.toolsets[toolset], funcName)
+	}
+}
+
+// Toolset returns the names of the functions in the toolset in the order of registration
+func (a *Agent) Toolset(toolset string) []string {
+	return append([]string(nil), a.toolsets[toolset]...)
+}
+
+// allowedFuncs returns the names of the functions allowed by the options in the order of registration,
+// nil if all of them are allowed
+func (a *Agent) allowedFuncs(opts []CallOption) ([]string, error) {
+	var callOpts CallOpts
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+	if !callOpts.scoped {
+		return nil, nil
+	}
+	allowed := make(map[string]bool)
+	for _, toolset := range callOpts.Toolsets {
+		funcNames, ok := a.toolsets[toolset]
+		if !ok {
+			return nil, fmt.Errorf("allowed toolset %s does not exist", toolset)
+		}
+		for _, name := range funcNames {
+			allowed[name] = true
+		}
+	}
+	for _, name := range callOpts.Funcs {
+		if _, ok := a.funcMap[name]; !ok {
+			return nil, fmt.Errorf("allowed function %s is not registered", name)
+		}
+		allowed[name] = true
+	}
+	names := make([]string, 0, len(allowed))
+	for _, name := range a.funcKeys {
+		if allowed[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no registered functions are allowed by toolsets %v and functions %v", callOpts.Toolsets, callOpts.Funcs)
+	}
+	return names, nil
+}
+
+// resolve resolves the user input to a call of the functions allowed by the options,
+// the resolver is scoped to them if it implements ScopedResolver and the resolved names are checked anyway
+func (a *Agent) resolve(ctx context.Context, userInput string, opts []CallOption) (*function.Call, error) {
+	allowed, err := a.allowedFuncs(opts)
+	if err != nil {
+		return nil, err
+	}
+	if allowed == nil {
+		return a.resolver.Resolve(ctx, userInput)
+	}
+	var call *function.Call
+	if scopedResolver, ok := a.resolver.(ScopedResolver); ok {
+		call, err = scopedResolver.ResolveScoped(ctx, userInput, allowed)
+	} else {
+		call, err = a.resolver.Resolve(ctx, userInput)
+	}
+	if err != nil {
+		return nil, err
+	}
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		allowedSet[name] = true
+	}
+	if err = checkAllowed(call, allowedSet); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// checkAllowed checks the call and its nested calls are allowed
+func checkAllowed(call *function.Call, allowed map[string]bool) error {
+	if !allowed[call.Name] {
+		return FuncNotAllowedErr{Name: call.Name}
+	}
+	for _, nested := range call.Params.NestedCalls() {
+		if err := checkAllowed(nested, allowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}